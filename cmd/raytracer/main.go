@@ -2,32 +2,279 @@ package main
 
 import (
 	"flag"
-	"fmt"
-	"unsafe"
+	"log"
+	"os"
+	"strings"
 
 	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+	"github.com/gabrielfvale/go-raytracer/pkg/scenefile"
 	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
 	"github.com/gabrielfvale/go-raytracer/pkg/util"
-	"github.com/veandco/go-sdl2/sdl"
 )
 
 func main() {
 
 	var width int
+	var height int
+	var scale float64
+	var crop string
 	var samples int
 	var nphotons int
 	var output string
+	var sceneFile string
+	var camera string
+	var integrator string
+	var fov float64
+	var aperture float64
+	var focus float64
+	var savePhotons string
+	var loadPhotons string
+	var photonStats bool
+	var photonPLY string
+	var tonemap string
+	var exposure float64
+	var white float64
+	var exrType string
+	var exrCompression string
 
-	flag.IntVar(&width, "w", 640, "Scene width.")
+	flag.IntVar(&width, "w", 640, "Image width.")
+	flag.IntVar(&height, "h", 0, "Image height, 0 for the aspect ratio of the scene (square for the default scene).")
+	flag.Float64Var(&scale, "scale", 100, "Resolution scale, in percent of the width and height.")
+	flag.StringVar(&crop, "crop", "", "Render only the crop window xmin,ymin,xmax,ymax of the frame, in fractions of the width and height.")
 	flag.IntVar(&samples, "s", 8, "Amount of samples per pixel.")
-	flag.IntVar(&nphotons, "p", 100000, "Number of photons per photon map.")
-	flag.StringVar(&output, "o", "", "Output image (PNG).")
+	flag.IntVar(&nphotons, "p", 100000, "Number of photons in the global photon map (half as many for caustics).")
+	flag.StringVar(&output, "o", "", "Output image (PNG, or EXR, HDR and PFM for linear radiance).")
+	flag.StringVar(&sceneFile, "scene", "", "Scene description file (YAML or JSON).")
+	flag.StringVar(&integrator, "integrator", "path", "Rendering algorithm ("+strings.Join(scenefile.Integrators, ", ")+").")
+	flag.StringVar(&camera, "camera", "perspective", "Camera projection ("+strings.Join(scenefile.CameraTypes, ", ")+").")
+	flag.Float64Var(&fov, "fov", 40, "Camera field of view in degrees (image circle for fisheye cameras).")
+	flag.Float64Var(&aperture, "aperture", 0, "Camera lens radius (0 for a pinhole camera).")
+	flag.Float64Var(&focus, "focus", 0, "Camera focus distance (0 to focus on the center of the image).")
+	flag.StringVar(&savePhotons, "save-photons", "", "Save the photon maps of the scene to a file.")
+	flag.StringVar(&loadPhotons, "load-photons", "", "Load the photon maps of the scene from a file saved with -save-photons.")
+	flag.BoolVar(&photonStats, "photon-stats", false, "Print statistics of the photon maps.")
+	flag.StringVar(&photonPLY, "photon-ply", "", "Export the photon maps as PLY point clouds, to <name>-global.ply and <name>-caustics.ply.")
+	flag.StringVar(&tonemap, "tonemap", "linear", "Tone mapping operator ("+strings.Join(scenefile.Tonemaps, ", ")+").")
+	flag.Float64Var(&exposure, "exposure", 0, "Exposure compensation in stops (EV).")
+	flag.Float64Var(&white, "white", 0, "White point of the reinhard-extended and hable tone mappings, 0 for their defaults.")
+	flag.StringVar(&exrType, "exr-type", "half", "Pixel type of EXR images (half, float).")
+	flag.StringVar(&exrCompression, "exr-compression", "zip", "Compression of EXR images (zip, none).")
 	flag.Parse()
 
-	aspect := 1.0
-	height := int(float64(width) / aspect)
+	var exr util.EXROptions
+	switch exrType {
+	case "half":
+	case "float":
+		exr.Float = true
+	default:
+		log.Fatalf("unknown EXR pixel type %q", exrType)
+	}
+	switch exrCompression {
+	case "none":
+	case "zip":
+		exr.Zip = true
+	default:
+		log.Fatalf("unknown EXR compression %q", exrCompression)
+	}
+	if white < 0 {
+		log.Fatal("The white point must not be negative")
+	}
+	if width <= 0 || height < 0 || scale <= 0 {
+		log.Fatal("The width, height and scale must be positive")
+	}
+	if nphotons < 0 {
+		log.Fatal("The number of photons must not be negative")
+	}
+	cropWindow := scenefile.DefaultSettings.Crop
+	if crop != "" {
+		var err error
+		if cropWindow, err = scenefile.ParseCrop(crop); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var scene tracer.Scene
+	if sceneFile != "" {
+		scene, samples = loadScene(sceneFile, cropWindow)
+	} else {
+		if height == 0 {
+			height = width
+		}
+		settings := scenefile.DefaultSettings
+		settings.Width, settings.Height, settings.Scale, settings.Crop = width, height, scale, cropWindow
+		settings.Samples, settings.Photons = samples, nphotons
+		settings.Integrator, settings.Tonemap, settings.Exposure, settings.White = integrator, tonemap, exposure, white
+		spec := scenefile.CameraSpec{
+			Type:     camera,
+			Eye:      geom.NewVec3(278, 273, -800),
+			LookAt:   geom.NewVec3(278, 278, 1),
+			Up:       geom.NewVec3(0, 1, 0),
+			FOV:      fov,
+			Aperture: aperture,
+			Focus:    focus,
+		}
+		checkSettings(settings, spec)
+		w, h := settings.Resolution()
+		scene = cornellBox(w, h, nphotons, spec)
+		if scene.Crop = settings.CropWindow(w, h); scene.Crop.Empty() {
+			log.Fatalf("The crop window holds no pixel of the %dx%d image", w, h)
+		}
+		var err error
+		if scene.Integrator, err = scenefile.NewIntegrator(integrator); err != nil {
+			log.Fatal(err)
+		}
+		scene.Tonemap = tracer.ToneMapping{Exposure: exposure, White: white}
+		if scene.Tonemap.Operator, err = scenefile.NewToneOperator(tonemap); err != nil {
+			log.Fatal(err)
+		}
+	}
+	win := scene.Window()
+
+	if loadPhotons != "" {
+		f, err := os.Open(loadPhotons)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = scene.LoadPhotons(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Photon maps", loadPhotons, "loaded")
+	}
+	if savePhotons != "" {
+		f, err := os.Create(savePhotons)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := scene.SavePhotons(f); err != nil {
+			f.Close()
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Photon maps", savePhotons, "saved")
+	}
+	if photonStats {
+		global, caustics := scene.PhotonMaps()
+		log.Println("Global map:", global.Stats(100, 1000))
+		log.Println("Caustics map:", caustics.Stats(100, 1000))
+	}
+	if photonPLY != "" {
+		global, caustics := scene.PhotonMaps()
+		writePLY(photonPLY+"-global.ply", global)
+		writePLY(photonPLY+"-caustics.ply", caustics)
+	}
+
+	if output == "" && !preview {
+		log.Fatal("No output image given with -o, and the preview window needs a build with -tags sdl")
+	}
+	film := tracer.NewFilm(win.Dx(), win.Dy())
+	scene.Render(film, samples)
+	if output != "" { // render to image
+		if util.IsFloatImage(output) {
+			util.SaveFloatImage("output/"+output, film.FloatImage(), exr)
+		} else {
+			util.SaveToImage("output/"+output, film.Framebuffer(scene.Tonemap))
+		}
+		return
+	}
+	showWindow(film.Framebuffer(scene.Tonemap))
+}
+
+// loadScene reads a scene file, letting the command line
+// flags given explicitly override its render settings
+func loadScene(name string, crop [4]float64) (tracer.Scene, int) {
+	f, err := scenefile.Load(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var w, h int
+	flag.Visit(func(fl *flag.Flag) {
+		v := fl.Value.(flag.Getter).Get()
+		switch fl.Name {
+		case "w":
+			w = v.(int)
+		case "h":
+			h = v.(int)
+		case "scale":
+			f.Settings.Scale = v.(float64)
+		case "crop":
+			f.Settings.Crop = crop
+		case "s":
+			f.Settings.Samples = v.(int)
+		case "p":
+			f.Settings.Photons = v.(int)
+		case "integrator":
+			f.Settings.Integrator = v.(string)
+		case "tonemap":
+			f.Settings.Tonemap = v.(string)
+		case "exposure":
+			f.Settings.Exposure = v.(float64)
+		case "white":
+			f.Settings.White = v.(float64)
+		case "camera":
+			f.Camera.Type = v.(string)
+		case "fov":
+			f.Camera.FOV = v.(float64)
+		case "aperture":
+			f.Camera.Aperture = v.(float64)
+		case "focus":
+			f.Camera.Focus = v.(float64)
+		}
+	})
+	// Keep the aspect ratio of the scene file unless both are given
+	switch {
+	case w > 0 && h > 0:
+		f.Settings.Width, f.Settings.Height = w, h
+	case w > 0:
+		f.Settings.Width, f.Settings.Height = w, w*f.Settings.Height/f.Settings.Width
+	case h > 0:
+		f.Settings.Width, f.Settings.Height = h*f.Settings.Width/f.Settings.Height, h
+	}
+	checkSettings(f.Settings, f.Camera)
+	scene, err := f.Scene()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return scene, f.Settings.Samples
+}
+
+// settingFlags maps the render and camera settings to their flags
+var settingFlags = map[string]string{
+	"width":      "w",
+	"height":     "h",
+	"scale":      "scale",
+	"crop":       "crop",
+	"samples":    "s",
+	"photons":    "p",
+	"integrator": "integrator",
+	"tonemap":    "tonemap",
+	"white":      "white",
+	"type":       "camera",
+	"fov":        "fov",
+	"aperture":   "aperture",
+	"focus":      "focus",
+}
+
+// checkSettings validates the render and camera settings given on
+// the command line, or in a scene file overridden by the flags
+func checkSettings(s scenefile.Settings, c scenefile.CameraSpec) {
+	for _, err := range []error{s.Validate(), c.Validate()} {
+		if se, ok := err.(*scenefile.SettingError); ok && settingFlags[se.Key] != "" {
+			log.Fatalf("Invalid -%s: %s", settingFlags[se.Key], se.Msg)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
 
-	// fmt.Println(width, height)
+// cornellBox returns the default scene, a Cornell box
+// with a mirror sphere and a glass sphere
+func cornellBox(width, height, nphotons int, camera scenefile.CameraSpec) tracer.Scene {
+	aspect := float64(width) / float64(height)
 
 	matRed := tracer.LambertMaterial(tracer.NewColor(0.65, 0.05, 0.05))
 	matGreen := tracer.LambertMaterial(tracer.NewColor(0.12, 0.45, 0.15))
@@ -48,75 +295,28 @@ func main() {
 		tracer.NewSphere(geom.NewVec3(278-110, 90, 227-40), 90, matGlass),
 	}
 
-	cam := tracer.NewCamera(
-		geom.NewVec3(278, 273, -800),
-		geom.NewVec3(278, 278, 1),
-		geom.NewVec3(0, 1, 0),
-		40, aspect)
-
-	globalMap := tracer.NewPhotonMap(100000)
-	causticsMap := tracer.NewPhotonMap(50000)
-	scene := tracer.NewScene(width, height, cam, objects, &globalMap, &causticsMap)
-
-	if output != "" { // render to image
-		bpp := int(unsafe.Sizeof(uint32(0)))
-		pitch := bpp * height
-		pixels := make([]uint8, width*pitch)
-		scene.Render(pixels, pitch, samples)
-		util.SaveToImage("output/" + output, width, height, pixels)
-		return
-	}
-
-	/* Begin SDL startup */
-	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
-		panic(err)
-	}
-	defer sdl.Quit()
-
-	window, err := sdl.CreateWindow("GO Raytracer", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
-		int32(width), int32(height), sdl.WINDOW_SHOWN)
+	cam, err := camera.Camera(aspect)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
-	defer window.Destroy()
 
-	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
-	if err != nil {
-		panic(err)
-	}
-	defer renderer.Destroy()
+	globalMap := tracer.NewPhotonMap(nphotons)
+	causticsMap := tracer.NewPhotonMap(nphotons / 2)
+	return tracer.NewScene(width, height, cam, objects, &globalMap, &causticsMap)
+}
 
-	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_RGB888, sdl.TEXTUREACCESS_STREAMING,
-		int32(width), int32(height))
+// writePLY exports the photons of pmap to the named PLY file
+func writePLY(name string, pmap *tracer.PhotonMap) {
+	f, err := os.Create(name)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
-	defer texture.Destroy()
-	/* End SDL startup */
-
-	pixels, pitch, err := texture.Lock(nil)
-	if err != nil {
-		panic(err)
+	if err := pmap.WritePLY(f); err != nil {
+		f.Close()
+		log.Fatal(err)
 	}
-
-	scene.Render(pixels, pitch, samples)
-
-	texture.Update(nil, pixels, pitch)
-	texture.Unlock()
-
-	renderer.Clear()
-	renderer.Copy(texture, nil, nil)
-	renderer.Present()
-
-	running := true
-	for running {
-		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			switch event.(type) {
-			case *sdl.QuitEvent:
-				fmt.Println("Quit")
-				running = false
-				break
-			}
-		}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
 	}
+	log.Println("Photons", name, "saved")
 }
@@ -0,0 +1,12 @@
+//go:build !sdl
+// +build !sdl
+
+package main
+
+import "github.com/gabrielfvale/go-raytracer/pkg/tracer"
+
+// preview tells if renders can be shown in a window,
+// which needs a build with the sdl tag
+const preview = false
+
+func showWindow(fb *tracer.Framebuffer) {}
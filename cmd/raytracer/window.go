@@ -0,0 +1,64 @@
+//go:build sdl
+// +build sdl
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// preview tells if renders can be shown in a window
+const preview = true
+
+// showWindow shows the framebuffer in an SDL window until it is closed
+func showWindow(fb *tracer.Framebuffer) {
+	/* Begin SDL startup */
+	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
+		panic(err)
+	}
+	defer sdl.Quit()
+
+	window, err := sdl.CreateWindow("GO Raytracer", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
+		int32(fb.W), int32(fb.H), sdl.WINDOW_SHOWN)
+	if err != nil {
+		panic(err)
+	}
+	defer window.Destroy()
+
+	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
+	if err != nil {
+		panic(err)
+	}
+	defer renderer.Destroy()
+
+	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_RGB24, sdl.TEXTUREACCESS_STREAMING,
+		int32(fb.W), int32(fb.H))
+	if err != nil {
+		panic(err)
+	}
+	defer texture.Destroy()
+	/* End SDL startup */
+
+	if err := texture.Update(nil, fb.Pix, fb.Stride()); err != nil {
+		panic(err)
+	}
+
+	renderer.Clear()
+	renderer.Copy(texture, nil, nil)
+	renderer.Present()
+
+	running := true
+	for running {
+		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			switch event.(type) {
+			case *sdl.QuitEvent:
+				fmt.Println("Quit")
+				running = false
+				break
+			}
+		}
+	}
+}
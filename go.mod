@@ -4,6 +4,5 @@ go 1.15
 
 require (
 	github.com/veandco/go-sdl2 v0.4.8
-	golang.org/x/exp v0.0.0-20210712160552-8235cf48b5f6 // indirect
-	gonum.org/v1/gonum v0.9.3
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -130,34 +130,36 @@ func (v Vec3) Reflect(n Vec3) Vec3 {
 	return v.Minus(n.Scale(2 * v.Dot(n))).Unit()
 }
 
-// Refract returns a refracted Vec3
+// Refract returns v refracted through a surface with normal n, given
+// the ratio of the indices of refraction. Both vectors must be unit
+// length, with n facing against v. Returns false on total internal
+// reflection.
 func (v Vec3) Refract(n Vec3, etaRatio float64) (refracts bool, r Vec3) {
-	refrN := n
-	ratio := etaRatio
-
-	if v.Dot(n) >= 0 { // ray inside
-		refrN = n.Inv()     // invert normal
-		ratio = 1.0 / ratio // effectively swap indexes
-	}
-
-	cosi := math.Min(v.Inv().Dot(refrN), 1.0)
-	sini := math.Sqrt(1.0 - cosi*cosi)
-
-	// Check for total internal reflection
-	totalIntRefl := ratio*sini > 1.0
-	// Use Schlick's approximation for reflectance
-	r0 := (1 - ratio) / (1 + ratio)
-	r0 = r0 * r0
-	r0 = r0 + (1-r0)*math.Pow((1-cosi), 5)
-	if totalIntRefl || r0 > rand.Float64() {
+	cosi := math.Min(v.Inv().Dot(n), 1.0)
+	sin2t := etaRatio * etaRatio * (1.0 - cosi*cosi)
+	if sin2t > 1.0 {
 		return false, r
 	}
-
-	r1 := v.Plus(refrN.Scale(cosi)).Scale(ratio)
-	r2 := refrN.Scale(-1 * math.Sqrt(math.Abs(1.0-r1.LenSq())))
+	r1 := v.Plus(n.Scale(cosi)).Scale(etaRatio)
+	r2 := n.Scale(-math.Sqrt(1.0 - sin2t))
 	return true, r1.Plus(r2).Unit()
 }
 
+// Fresnel returns the fraction of unpolarized light reflected by a
+// dielectric surface, given the cosine of the incident angle and the
+// ratio of the indices of refraction. Returns 1 on total internal
+// reflection.
+func Fresnel(cosi, etaRatio float64) float64 {
+	sin2t := etaRatio * etaRatio * (1.0 - cosi*cosi)
+	if sin2t >= 1.0 {
+		return 1.0
+	}
+	cost := math.Sqrt(1.0 - sin2t)
+	rs := (etaRatio*cosi - cost) / (etaRatio*cosi + cost)
+	rp := (cosi - etaRatio*cost) / (cosi + etaRatio*cost)
+	return (rs*rs + rp*rp) / 2
+}
+
 // SampleSphere returns a random unit vector in a sphere
 func SampleSphere(rnd *rand.Rand) Vec3 {
 	u1 := rnd.Float64()
@@ -169,6 +171,13 @@ func SampleSphere(rnd *rand.Rand) Vec3 {
 	return NewVec3(x, y, z).Unit()
 }
 
+// SampleDisk returns a random point in the unit disk on the XY plane
+func SampleDisk(rnd *rand.Rand) Vec3 {
+	r := math.Sqrt(rnd.Float64())
+	th := 2 * math.Pi * rnd.Float64()
+	return NewVec3(r*math.Cos(th), r*math.Sin(th), 0)
+}
+
 // SampleHemisphere returns a random unit vector in a hemisphere
 func SampleHemisphere(rnd *rand.Rand) Vec3 {
 	u1 := rnd.Float64()
@@ -0,0 +1,162 @@
+package loader
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register decoders for map_Kd textures
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
+)
+
+// MTL holds the parameters of a Wavefront material
+type MTL struct {
+	Name  string
+	Kd    tracer.Color // diffuse color
+	Ks    tracer.Color // specular color
+	Ke    tracer.Color // emissive color
+	Ns    float64      // specular exponent
+	Ni    float64      // index of refraction
+	D     float64      // dissolve (opacity)
+	MapKd string       // diffuse texture path
+}
+
+// LoadMTL parses a Wavefront material library
+func LoadMTL(name string) (map[string]*MTL, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mtls := make(map[string]*MTL)
+	var cur *MTL
+	dir := filepath.Dir(name)
+	line := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line++
+		fields := strings.Fields(stripComment(sc.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "newmtl" {
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%s:%d: newmtl without a name", name, line)
+			}
+			cur = &MTL{Name: fields[1], Kd: tracer.NewColor(0.8, 0.8, 0.8), Ns: 0, Ni: 1, D: 1}
+			mtls[cur.Name] = cur
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		switch fields[0] {
+		case "Kd":
+			cur.Kd, err = parseColor(fields)
+		case "Ks":
+			cur.Ks, err = parseColor(fields)
+		case "Ke":
+			cur.Ke, err = parseColor(fields)
+		case "Ns":
+			cur.Ns, err = parseFloat(fields, 1)
+		case "Ni":
+			cur.Ni, err = parseFloat(fields, 1)
+		case "d":
+			cur.D, err = parseFloat(fields, 1)
+		case "Tr":
+			var tr float64
+			tr, err = parseFloat(fields, 1)
+			cur.D = 1.0 - tr
+		case "map_Kd":
+			if len(fields) < 2 {
+				err = fmt.Errorf("map_Kd without a file")
+				break
+			}
+			// Texture options precede the file name
+			cur.MapKd = filepath.Join(dir, filepath.FromSlash(fields[len(fields)-1]))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return mtls, nil
+}
+
+// Material maps the Wavefront parameters to a tracer.Material:
+// emissive materials become lights, transparent ones become
+// dielectrics, mostly specular ones become metals and everything
+// else is lambertian.
+func (m *MTL) Material(textures map[string]tracer.Texture) (tracer.Material, error) {
+	if maxComponent(m.Ke) > 0 {
+		return tracer.LightMaterial(m.Ke, 1), nil
+	}
+	if m.D < 1 {
+		ior := m.Ni
+		if ior <= 1 {
+			ior = 1.5
+		}
+		return tracer.DielectricMaterial(ior), nil
+	}
+	if maxComponent(m.Ks) > maxComponent(m.Kd) {
+		roughness := math.Sqrt(2.0 / (m.Ns + 2.0))
+		return tracer.MetalicMaterial(m.Ks, 1, roughness), nil
+	}
+	mat := tracer.LambertMaterial(m.Kd)
+	if m.MapKd != "" {
+		tex, ok := textures[m.MapKd]
+		if !ok {
+			img, err := loadImage(m.MapKd)
+			if err != nil {
+				return mat, err
+			}
+			tex = tracer.NewImageTexture(img)
+			textures[m.MapKd] = tex
+		}
+		mat.Texture = tex
+	}
+	return mat, nil
+}
+
+func loadImage(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return img, nil
+}
+
+func maxComponent(c tracer.Color) float64 {
+	return math.Max(math.Max(c.R(), c.G()), c.B())
+}
+
+func parseColor(fields []string) (c tracer.Color, err error) {
+	if len(fields) > 1 && (fields[1] == "spectral" || fields[1] == "xyz") {
+		return c, fmt.Errorf("%s: unsupported color format %q", fields[0], fields[1])
+	}
+	var rgb [3]float64
+	for i := 0; i < 3; i++ {
+		// A single value is repeated for g and b
+		j := i + 1
+		if j >= len(fields) {
+			j = 1
+		}
+		if rgb[i], err = parseFloat(fields, j); err != nil {
+			return
+		}
+	}
+	return tracer.NewColor(rgb[0], rgb[1], rgb[2]), nil
+}
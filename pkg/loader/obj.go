@@ -0,0 +1,274 @@
+// Package loader reads external asset formats into tracer objects.
+package loader
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
+)
+
+// faceVertex holds resolved (0-based) indices of a face corner,
+// -1 meaning the attribute is absent
+type faceVertex struct {
+	v, vt, vn int
+}
+
+// meshKey identifies the triangles that share a group and material
+type meshKey struct {
+	group, mtl string
+}
+
+// objParser holds the state while reading an OBJ file
+type objParser struct {
+	name      string
+	line      int
+	vertices  []geom.Vec3
+	texcoords [][2]float64
+	normals   []geom.Vec3
+	mtls      map[string]*MTL
+	group     string
+	mtl       string
+	keys      []meshKey
+	tris      map[meshKey][]tracer.Triangle
+}
+
+// LoadOBJ parses a Wavefront OBJ file and the material libraries it
+// references, returning one Mesh per group and material. Faces without
+// a known material use fallback.
+func LoadOBJ(name string, fallback tracer.Material) ([]tracer.Hitable, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &objParser{
+		name:  name,
+		mtls:  make(map[string]*MTL),
+		group: "default",
+		tris:  make(map[meshKey][]tracer.Triangle),
+	}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var pending string
+	for sc.Scan() {
+		p.line++
+		text := stripComment(sc.Text())
+		// Backslash continues a statement on the next line
+		if strings.HasSuffix(text, "\\") {
+			pending += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		text, pending = pending+text, ""
+		if err := p.parseLine(strings.Fields(text)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, p.line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return p.meshes(fallback)
+}
+
+func (p *objParser) parseLine(fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	switch fields[0] {
+	case "v":
+		v, err := parseVec3(fields)
+		if err != nil {
+			return err
+		}
+		p.vertices = append(p.vertices, v)
+	case "vt":
+		u, err := parseFloat(fields, 1)
+		if err != nil {
+			return err
+		}
+		var v float64
+		if len(fields) > 2 {
+			if v, err = parseFloat(fields, 2); err != nil {
+				return err
+			}
+		}
+		p.texcoords = append(p.texcoords, [2]float64{u, v})
+	case "vn":
+		n, err := parseVec3(fields)
+		if err != nil {
+			return err
+		}
+		p.normals = append(p.normals, n)
+	case "f":
+		return p.parseFace(fields[1:])
+	case "g", "o":
+		if len(fields) > 1 {
+			p.group = strings.Join(fields[1:], " ")
+		} else {
+			p.group = "default"
+		}
+	case "usemtl":
+		if len(fields) < 2 {
+			return fmt.Errorf("usemtl without a name")
+		}
+		p.mtl = fields[1]
+	case "mtllib":
+		dir := filepath.Dir(p.name)
+		for _, lib := range fields[1:] {
+			mtls, err := LoadMTL(filepath.Join(dir, filepath.FromSlash(lib)))
+			if err != nil {
+				log.Printf("Skipping material library: %v", err)
+				continue
+			}
+			for k, m := range mtls {
+				p.mtls[k] = m
+			}
+		}
+	}
+	// Other statements (s, l, p, curves...) are ignored
+	return nil
+}
+
+// parseFace triangulates a polygon as a fan around its first vertex
+func (p *objParser) parseFace(corners []string) error {
+	if len(corners) < 3 {
+		return fmt.Errorf("face with %d vertices", len(corners))
+	}
+	fv := make([]faceVertex, len(corners))
+	for i, c := range corners {
+		var err error
+		if fv[i], err = p.parseCorner(c); err != nil {
+			return err
+		}
+	}
+
+	key := meshKey{group: p.group, mtl: p.mtl}
+	if _, ok := p.tris[key]; !ok {
+		p.keys = append(p.keys, key)
+	}
+	for i := 1; i+1 < len(fv); i++ {
+		a, b, c := fv[0], fv[i], fv[i+1]
+		v := [3]geom.Vec3{p.vertices[a.v], p.vertices[b.v], p.vertices[c.v]}
+		var n *[3]geom.Vec3
+		if a.vn >= 0 && b.vn >= 0 && c.vn >= 0 {
+			n = &[3]geom.Vec3{p.normals[a.vn], p.normals[b.vn], p.normals[c.vn]}
+		}
+		var uv *[3][2]float64
+		if a.vt >= 0 && b.vt >= 0 && c.vt >= 0 {
+			uv = &[3][2]float64{p.texcoords[a.vt], p.texcoords[b.vt], p.texcoords[c.vt]}
+		}
+		tri := tracer.NewSmoothTriangle(&v, n, uv, nil)
+		if tri.Area() == 0.0 {
+			continue // skip degenerate triangles
+		}
+		p.tris[key] = append(p.tris[key], tri)
+	}
+	return nil
+}
+
+// parseCorner parses a v, v/vt, v//vn or v/vt/vn face corner
+func (p *objParser) parseCorner(s string) (fv faceVertex, err error) {
+	parts := strings.Split(s, "/")
+	if len(parts) > 3 {
+		return fv, fmt.Errorf("invalid face vertex %q", s)
+	}
+	fv = faceVertex{v: -1, vt: -1, vn: -1}
+	if fv.v, err = resolveIndex(parts[0], len(p.vertices)); err != nil {
+		return
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if fv.vt, err = resolveIndex(parts[1], len(p.texcoords)); err != nil {
+			return
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if fv.vn, err = resolveIndex(parts[2], len(p.normals)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// meshes builds one Mesh per group and material, in file order
+func (p *objParser) meshes(fallback tracer.Material) ([]tracer.Hitable, error) {
+	textures := make(map[string]tracer.Texture)
+	materials := make(map[string]tracer.Material)
+	var meshes []tracer.Hitable
+	for _, key := range p.keys {
+		tris := p.tris[key]
+		if len(tris) == 0 {
+			continue
+		}
+		mat, ok := materials[key.mtl]
+		if !ok {
+			mat = fallback
+			if m, found := p.mtls[key.mtl]; found {
+				var err error
+				if mat, err = m.Material(textures); err != nil {
+					return nil, err
+				}
+			} else if key.mtl != "" {
+				log.Printf("%s: unknown material %q, using fallback", p.name, key.mtl)
+			}
+			materials[key.mtl] = mat
+		}
+		meshes = append(meshes, tracer.NewMesh(tris, mat))
+	}
+	return meshes, nil
+}
+
+// resolveIndex converts a 1-based (or negative, relative) OBJ index
+// into a 0-based index into a list of n elements
+func resolveIndex(s string, n int) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid index %q", s)
+	}
+	switch {
+	case i > 0:
+		i--
+	case i < 0:
+		i += n
+	default:
+		return 0, fmt.Errorf("invalid index 0")
+	}
+	if i < 0 || i >= n {
+		return 0, fmt.Errorf("index %s out of range", s)
+	}
+	return i, nil
+}
+
+func parseVec3(fields []string) (v geom.Vec3, err error) {
+	for i := 0; i < 3; i++ {
+		if v.E[i], err = parseFloat(fields, i+1); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func parseFloat(fields []string, i int) (float64, error) {
+	if i >= len(fields) {
+		return 0, fmt.Errorf("%s: missing value", fields[0])
+	}
+	f, err := strconv.ParseFloat(fields[i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid number %q", fields[0], fields[i])
+	}
+	return f, nil
+}
+
+func stripComment(s string) string {
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package scenefile
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
+)
+
+// CameraTypes lists the supported camera projections
+var CameraTypes = []string{
+	"perspective",
+	"orthographic",
+	"fisheye",
+	"fisheye-equisolid",
+	"equirectangular",
+}
+
+// CameraSpec describes a camera independently of the image size
+type CameraSpec struct {
+	Type            string
+	Eye, LookAt, Up geom.Vec3
+	FOV             float64 // vertical field of view, or image circle of fisheyes
+	Aperture        float64 // lens radius of perspective cameras
+	Focus           float64 // focus distance, 0 for autofocus
+}
+
+// DefaultCamera is used for the fields a scene file does not set
+var DefaultCamera = CameraSpec{
+	Type:   "perspective",
+	Eye:    geom.NewVec3(0, 0, 0),
+	LookAt: geom.NewVec3(0, 0, 1),
+	Up:     geom.NewVec3(0, 1, 0),
+	FOV:    40,
+}
+
+func validCamera(typ string) bool {
+	for _, t := range CameraTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the camera settings, returning a SettingError
+func (c CameraSpec) Validate() error {
+	if !validCamera(c.Type) {
+		return &SettingError{"type", fmt.Sprintf("unknown camera type %q (expected one of %s)", c.Type, strings.Join(CameraTypes, ", "))}
+	}
+	if c.FOV <= 0 || c.FOV > 360 || (c.FOV >= 180 && !strings.HasPrefix(c.Type, "fisheye")) {
+		return &SettingError{"fov", fmt.Sprintf("field of view out of range for a %s camera", c.Type)}
+	}
+	if c.Eye.Minus(c.LookAt).NearZero() {
+		return &SettingError{"", "eye and lookat must differ"}
+	}
+	if c.Aperture < 0 {
+		return &SettingError{"aperture", "must not be negative"}
+	}
+	if c.Focus < 0 {
+		return &SettingError{"focus", "must not be negative"}
+	}
+	return nil
+}
+
+// Camera returns the camera for an image with the given aspect ratio.
+// Orthographic cameras frame the same view as a perspective camera
+// would at the lookat distance.
+func (c CameraSpec) Camera(aspect float64) (tracer.Camera, error) {
+	switch c.Type {
+	case "perspective", "":
+		return tracer.NewPerspectiveCamera(c.Eye, c.LookAt, c.Up, c.FOV, aspect, c.Aperture, c.Focus), nil
+	case "orthographic":
+		height := 2 * math.Tan(c.FOV*math.Pi/360) * c.LookAt.Minus(c.Eye).Len()
+		return tracer.NewOrthographicCamera(c.Eye, c.LookAt, c.Up, height, aspect), nil
+	case "fisheye":
+		return tracer.NewFisheyeCamera(c.Eye, c.LookAt, c.Up, c.FOV, aspect, tracer.Equidistant), nil
+	case "fisheye-equisolid":
+		return tracer.NewFisheyeCamera(c.Eye, c.LookAt, c.Up, c.FOV, aspect, tracer.Equisolid), nil
+	case "equirectangular":
+		return tracer.NewEquirectangularCamera(c.Eye, c.LookAt, c.Up), nil
+	}
+	return nil, fmt.Errorf("unknown camera type %q", c.Type)
+}
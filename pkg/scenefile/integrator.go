@@ -0,0 +1,54 @@
+package scenefile
+
+import (
+	"fmt"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
+)
+
+// Integrators lists the supported rendering algorithms
+var Integrators = []string{
+	"path",
+	"photon",
+	"photon-global",
+	"photon-caustics",
+	"photon-global-splats",
+	"photon-caustics-splats",
+	"sppm",
+	"direct",
+	"ao",
+	"normals",
+	"depth",
+	"albedo",
+}
+
+// NewIntegrator returns the integrator with the given name
+func NewIntegrator(name string) (tracer.Integrator, error) {
+	switch name {
+	case "path":
+		return tracer.PathIntegrator{}, nil
+	case "photon":
+		return tracer.PhotonIntegrator{Global: true, Caustics: true, Gather: 4}, nil
+	case "photon-global":
+		return tracer.PhotonIntegrator{Global: true}, nil
+	case "photon-caustics":
+		return tracer.PhotonIntegrator{Caustics: true}, nil
+	case "photon-global-splats":
+		return tracer.PhotonIntegrator{Global: true, Splats: true}, nil
+	case "photon-caustics-splats":
+		return tracer.PhotonIntegrator{Caustics: true, Splats: true}, nil
+	case "sppm":
+		return tracer.SPPMIntegrator{}, nil
+	case "direct":
+		return tracer.DirectIntegrator{}, nil
+	case "ao":
+		return tracer.AOIntegrator{}, nil
+	case "normals":
+		return tracer.DebugIntegrator{View: tracer.NormalView}, nil
+	case "depth":
+		return tracer.DebugIntegrator{View: tracer.DepthView}, nil
+	case "albedo":
+		return tracer.DebugIntegrator{View: tracer.AlbedoView}, nil
+	}
+	return nil, fmt.Errorf("unknown integrator %q", name)
+}
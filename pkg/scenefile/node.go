@@ -0,0 +1,223 @@
+package scenefile
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a scene file error, located by line and field path
+type Error struct {
+	File string
+	Line int
+	Path string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.File)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+	}
+	if e.Path != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Path)
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+// node is a YAML node along with its location in the document
+type node struct {
+	n    *yaml.Node
+	file string
+	path string
+}
+
+func (n node) errorf(format string, args ...interface{}) error {
+	return &Error{File: n.file, Line: n.n.Line, Path: n.path, Msg: fmt.Sprintf(format, args...)}
+}
+
+// child returns the node at key inside a mapping node
+func (n node) child(key string, v *yaml.Node) node {
+	path := key
+	if n.path != "" {
+		path = n.path + "." + key
+	}
+	return node{n: v, file: n.file, path: path}
+}
+
+// list returns the elements of a sequence node
+func (n node) list() ([]node, error) {
+	if n.n.Kind != yaml.SequenceNode {
+		return nil, n.errorf("expected a list")
+	}
+	l := make([]node, len(n.n.Content))
+	for i, c := range n.n.Content {
+		l[i] = node{n: c, file: n.file, path: fmt.Sprintf("%s[%d]", n.path, i)}
+	}
+	return l, nil
+}
+
+// listOrOne returns the elements of a sequence node,
+// or the node itself if it is a single value
+func (n node) listOrOne() ([]node, error) {
+	if n.n.Kind == yaml.SequenceNode {
+		return n.list()
+	}
+	return []node{n}, nil
+}
+
+func (n node) str() (string, error) {
+	if n.n.Kind != yaml.ScalarNode || n.n.Tag == "!!null" {
+		return "", n.errorf("expected a string")
+	}
+	return n.n.Value, nil
+}
+
+func (n node) float() (float64, error) {
+	if n.n.Kind != yaml.ScalarNode {
+		return 0, n.errorf("expected a number")
+	}
+	f, err := strconv.ParseFloat(n.n.Value, 64)
+	if err != nil {
+		return 0, n.errorf("expected a number, got %q", n.n.Value)
+	}
+	return f, nil
+}
+
+func (n node) int() (int, error) {
+	if n.n.Kind != yaml.ScalarNode {
+		return 0, n.errorf("expected an integer")
+	}
+	i, err := strconv.Atoi(n.n.Value)
+	if err != nil {
+		return 0, n.errorf("expected an integer, got %q", n.n.Value)
+	}
+	return i, nil
+}
+
+func (n node) bool() (bool, error) {
+	var b bool
+	if n.n.Kind != yaml.ScalarNode || n.n.Decode(&b) != nil {
+		return false, n.errorf("expected true or false")
+	}
+	return b, nil
+}
+
+func (n node) vec3() (v geom.Vec3, err error) {
+	l, err := n.list()
+	if err != nil || len(l) != 3 {
+		return v, n.errorf("expected a list of 3 numbers")
+	}
+	for i, c := range l {
+		if v.E[i], err = c.float(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// color accepts a list of 3 numbers or a single gray value
+func (n node) color() (tracer.Color, error) {
+	if n.n.Kind == yaml.ScalarNode {
+		g, err := n.float()
+		return tracer.NewColor(g, g, g), err
+	}
+	v, err := n.vec3()
+	return tracer.Color{Vec3: v}, err
+}
+
+// object is a mapping node with known fields
+type object struct {
+	node
+	fields map[string]node
+}
+
+// object checks that n is a mapping whose keys are all in known
+func (n node) object(known ...string) (object, error) {
+	o := object{node: n, fields: make(map[string]node)}
+	if n.n.Kind != yaml.MappingNode {
+		return o, n.errorf("expected a mapping")
+	}
+	allowed := make(map[string]bool, len(known))
+	for _, k := range known {
+		allowed[k] = true
+	}
+	for i := 0; i+1 < len(n.n.Content); i += 2 {
+		k, v := n.n.Content[i], n.n.Content[i+1]
+		if !allowed[k.Value] {
+			sort.Strings(known)
+			return o, n.child(k.Value, k).errorf("unknown field (expected one of %s)", strings.Join(known, ", "))
+		}
+		if _, dup := o.fields[k.Value]; dup {
+			return o, n.child(k.Value, k).errorf("duplicate field")
+		}
+		o.fields[k.Value] = n.child(k.Value, v)
+	}
+	return o, nil
+}
+
+// has returns if the object defines key
+func (o object) has(key string) bool {
+	_, ok := o.fields[key]
+	return ok
+}
+
+// required returns the node at key, failing if it is missing
+func (o object) required(key string) (node, error) {
+	f, ok := o.fields[key]
+	if !ok {
+		return f, o.errorf("missing field %q", key)
+	}
+	return f, nil
+}
+
+func (o object) str(key, def string) (string, error) {
+	if f, ok := o.fields[key]; ok {
+		return f.str()
+	}
+	return def, nil
+}
+
+func (o object) float(key string, def float64) (float64, error) {
+	if f, ok := o.fields[key]; ok {
+		return f.float()
+	}
+	return def, nil
+}
+
+func (o object) int(key string, def int) (int, error) {
+	if f, ok := o.fields[key]; ok {
+		return f.int()
+	}
+	return def, nil
+}
+
+func (o object) bool(key string, def bool) (bool, error) {
+	if f, ok := o.fields[key]; ok {
+		return f.bool()
+	}
+	return def, nil
+}
+
+func (o object) vec3(key string, def geom.Vec3) (geom.Vec3, error) {
+	if f, ok := o.fields[key]; ok {
+		return f.vec3()
+	}
+	return def, nil
+}
+
+func (o object) color(key string, def tracer.Color) (tracer.Color, error) {
+	if f, ok := o.fields[key]; ok {
+		return f.color()
+	}
+	return def, nil
+}
@@ -0,0 +1,325 @@
+package scenefile
+
+import (
+	"path/filepath"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+	"github.com/gabrielfvale/go-raytracer/pkg/loader"
+	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultColor is used by materials without a color
+var defaultColor = tracer.NewColor(0.73, 0.73, 0.73)
+
+// typeOf returns the value of the type field of a mapping node
+func typeOf(n node) (string, error) {
+	if n.n.Kind != yaml.MappingNode {
+		return "", n.errorf("expected a mapping")
+	}
+	for i := 0; i+1 < len(n.n.Content); i += 2 {
+		if n.n.Content[i].Value == "type" {
+			return n.child("type", n.n.Content[i+1]).str()
+		}
+	}
+	return "", n.errorf("missing field %q", "type")
+}
+
+// materialLibrary reads a mapping of named materials
+func (d *decoder) materialLibrary(n node) error {
+	if n.n.Kind != yaml.MappingNode {
+		return n.errorf("expected a mapping of material names")
+	}
+	for i := 0; i+1 < len(n.n.Content); i += 2 {
+		name := n.n.Content[i].Value
+		m, err := d.material(n.child(name, n.n.Content[i+1]))
+		if err != nil {
+			return err
+		}
+		d.materials[name] = m
+	}
+	return nil
+}
+
+// material reads an inline material definition
+func (d *decoder) material(n node) (m tracer.Material, err error) {
+	typ, err := typeOf(n)
+	if err != nil {
+		return
+	}
+	switch typ {
+	case "lambert", "diffuse":
+		o, err := n.object("type", "color")
+		if err != nil {
+			return m, err
+		}
+		c, err := o.color("color", defaultColor)
+		if typ == "diffuse" {
+			return tracer.DiffuseMaterial(c), err
+		}
+		return tracer.LambertMaterial(c), err
+	case "metal":
+		o, err := n.object("type", "color", "reflectivity", "roughness")
+		if err != nil {
+			return m, err
+		}
+		c, err := o.color("color", tracer.NewColor(1, 1, 1))
+		if err != nil {
+			return m, err
+		}
+		refl, err := o.float("reflectivity", 1)
+		if err != nil {
+			return m, err
+		}
+		rough, err := o.float("roughness", 0)
+		return tracer.MetalicMaterial(c, refl, rough), err
+	case "dielectric":
+		o, err := n.object("type", "ior", "color", "distance")
+		if err != nil {
+			return m, err
+		}
+		ior, err := o.float("ior", 1.5)
+		if err == nil && ior < 1 {
+			err = o.fields["ior"].errorf("index of refraction must be at least 1")
+		}
+		if err != nil || !o.has("color") {
+			return tracer.DielectricMaterial(ior), err
+		}
+		// Tinted glass keeps color after travelling distance
+		c, err := o.color("color", tracer.NewColor(1, 1, 1))
+		if err != nil {
+			return m, err
+		}
+		dist, err := o.float("distance", 1)
+		if err == nil && dist <= 0 {
+			err = o.fields["distance"].errorf("must be positive")
+		}
+		return tracer.TintedDielectricMaterial(ior, c, dist), err
+	case "light":
+		o, err := n.object("type", "color", "emittance")
+		if err != nil {
+			return m, err
+		}
+		return d.lightMaterial(o)
+	case "normal":
+		_, err := n.object("type")
+		return tracer.NormalMaterial(), err
+	}
+	return m, n.child("type", n.n).errorf("unknown material type %q", typ)
+}
+
+func (d *decoder) lightMaterial(o object) (m tracer.Material, err error) {
+	c, err := o.color("color", tracer.NewColor(1, 1, 1))
+	if err != nil {
+		return
+	}
+	e, err := o.float("emittance", 1)
+	if err == nil && e <= 0 {
+		err = o.fields["emittance"].errorf("must be positive")
+	}
+	return tracer.LightMaterial(c, e), err
+}
+
+// materialRef resolves a material name or inline definition
+func (d *decoder) materialRef(n node) (tracer.Material, error) {
+	if n.n.Kind == yaml.MappingNode {
+		return d.material(n)
+	}
+	name, err := n.str()
+	if err != nil {
+		return nil, err
+	}
+	m, ok := d.materials[name]
+	if !ok {
+		return m, n.errorf("unknown material %q", name)
+	}
+	return m, nil
+}
+
+// shape reads a shape. Lights take a color and emittance
+// instead of a material.
+func (d *decoder) shape(n node, light bool) ([]tracer.Hitable, error) {
+	typ, err := typeOf(n)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	switch typ {
+	case "sphere":
+		fields = []string{"center", "radius"}
+	case "box":
+		fields = []string{"min", "max"}
+	case "triangle":
+		fields = []string{"vertices", "normals"}
+	case "mesh":
+		fields = []string{"file", "scale", "translate"}
+	default:
+		return nil, n.child("type", n.n).errorf("unknown shape type %q", typ)
+	}
+	fields = append(fields, "type")
+	if light {
+		fields = append(fields, "color", "emittance")
+	} else {
+		fields = append(fields, "material")
+	}
+	o, err := n.object(fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	var mat tracer.Material
+	if light {
+		mat, err = d.lightMaterial(o)
+	} else if f, ok := o.fields["material"]; ok {
+		mat, err = d.materialRef(f)
+	} else if typ != "mesh" {
+		// Meshes may take their materials from MTL files
+		_, err = o.required("material")
+	} else {
+		mat = tracer.LambertMaterial(defaultColor)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case "sphere":
+		center, err := vec3Field(o, "center")
+		if err != nil {
+			return nil, err
+		}
+		radius, err := o.float("radius", 1)
+		if err == nil && radius <= 0 {
+			err = o.fields["radius"].errorf("must be positive")
+		}
+		return []tracer.Hitable{tracer.NewSphere(center, radius, mat)}, err
+	case "box":
+		min, err := vec3Field(o, "min")
+		if err != nil {
+			return nil, err
+		}
+		max, err := vec3Field(o, "max")
+		if err != nil {
+			return nil, err
+		}
+		if max.X() < min.X() || max.Y() < min.Y() || max.Z() < min.Z() {
+			return nil, o.fields["max"].errorf("must not be smaller than min")
+		}
+		return []tracer.Hitable{tracer.NewAABB(min, max, mat)}, nil
+	case "triangle":
+		return triangle(o, mat)
+	}
+	return d.mesh(o, mat, light)
+}
+
+// vec3Field reads a required vector field
+func vec3Field(o object, key string) (v geom.Vec3, err error) {
+	f, err := o.required(key)
+	if err != nil {
+		return
+	}
+	return f.vec3()
+}
+
+func triangle(o object, mat tracer.Material) ([]tracer.Hitable, error) {
+	readVerts := func(key string) (*[3]geom.Vec3, error) {
+		f, err := o.required(key)
+		if err != nil {
+			return nil, err
+		}
+		l, err := f.list()
+		if err != nil {
+			return nil, err
+		}
+		if len(l) != 3 {
+			return nil, f.errorf("expected 3 vectors")
+		}
+		var v [3]geom.Vec3
+		for i := range l {
+			if v[i], err = l[i].vec3(); err != nil {
+				return nil, err
+			}
+		}
+		return &v, nil
+	}
+	v, err := readVerts("vertices")
+	if err != nil {
+		return nil, err
+	}
+	var n *[3]geom.Vec3
+	if o.has("normals") {
+		if n, err = readVerts("normals"); err != nil {
+			return nil, err
+		}
+	}
+	tri := tracer.NewSmoothTriangle(v, n, nil, mat)
+	if tri.Area() == 0 {
+		return nil, o.fields["vertices"].errorf("degenerate triangle")
+	}
+	return []tracer.Hitable{tri}, nil
+}
+
+// mesh loads an OBJ file relative to the scene file, applying
+// scale and translation to its vertices. Lights and meshes with
+// an explicit material use mat instead of their MTL materials.
+func (d *decoder) mesh(o object, mat tracer.Material, light bool) ([]tracer.Hitable, error) {
+	f, err := o.required("file")
+	if err != nil {
+		return nil, err
+	}
+	path, err := f.str()
+	if err != nil {
+		return nil, err
+	}
+	scale := geom.NewVec3(1, 1, 1)
+	if s, ok := o.fields["scale"]; ok {
+		if s.n.Kind == yaml.ScalarNode {
+			k, err := s.float()
+			if err != nil {
+				return nil, err
+			}
+			scale = geom.NewVec3(k, k, k)
+		} else if scale, err = s.vec3(); err != nil {
+			return nil, err
+		}
+		if scale.X() == 0 || scale.Y() == 0 || scale.Z() == 0 {
+			return nil, s.errorf("scale must not be zero")
+		}
+	}
+	translate, err := o.vec3("translate", geom.NewVec3(0, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	meshes, err := loader.LoadOBJ(filepath.Join(filepath.Dir(o.file), filepath.FromSlash(path)), mat)
+	if err != nil {
+		return nil, f.errorf("%v", err)
+	}
+	override := light || o.has("material")
+	for i, h := range meshes {
+		m := h.(tracer.Mesh)
+		if override {
+			m.Mat = mat
+		}
+		meshes[i] = transform(m, scale, translate)
+	}
+	return meshes, nil
+}
+
+// transform returns a copy of m scaled and then translated, with
+// every triangle made of m.Mat. Normals are scaled by the inverse
+// to stay perpendicular.
+func transform(m tracer.Mesh, scale, translate geom.Vec3) tracer.Mesh {
+	inv := geom.NewVec3(1/scale.X(), 1/scale.Y(), 1/scale.Z())
+	tris := make([]tracer.Triangle, len(m.Triangles))
+	for i, t := range m.Triangles {
+		for j := 0; j < 3; j++ {
+			t.V[j] = t.V[j].Times(scale).Plus(translate)
+			if t.HasNormals {
+				t.N[j] = t.N[j].Times(inv).Unit()
+			}
+		}
+		tris[i] = t
+	}
+	return tracer.NewMesh(tris, m.Mat)
+}
@@ -0,0 +1,400 @@
+// Package scenefile loads declarative scene descriptions written in
+// YAML or JSON. A scene file has the following top-level fields, all
+// of them optional:
+//
+//	include:   other scene files merged before this one
+//	render:    width, height, scale, crop, samples, photons, depth,
+//	           integrator, tonemap, exposure and white
+//	camera:    type, eye, lookat, up, fov, aperture and focus
+//	materials: named materials, referenced by shapes
+//	shapes:    spheres, boxes, triangles and OBJ meshes
+//	lights:    shapes that emit light
+//
+// See scenes/cornell.yaml for a complete example.
+package scenefile
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings holds the render settings of a scene file
+type Settings struct {
+	Width, Height int
+	Scale         float64    // percentage of the width and height rendered
+	Crop          [4]float64 // fractions of the frame rendered: xmin, ymin, xmax, ymax
+	Samples       int
+	Photons       int
+	MaxDepth      int
+	Integrator    string
+	Tonemap       string
+	Exposure      float64 // in stops
+	White         float64 // white point of the tone mapping, 0 for its default
+}
+
+// DefaultSettings are used for the fields a scene file does not set
+var DefaultSettings = Settings{
+	Width:      640,
+	Height:     640,
+	Scale:      100,
+	Crop:       [4]float64{0, 0, 1, 1},
+	Samples:    8,
+	Photons:    100000,
+	MaxDepth:   6,
+	Integrator: "path",
+	Tonemap:    "linear",
+}
+
+// File is a loaded scene file
+type File struct {
+	Settings Settings
+	Camera   CameraSpec
+	Objects  []tracer.Hitable
+}
+
+// decoder holds the state shared by a file and its includes
+type decoder struct {
+	file      *File
+	materials map[string]tracer.Material
+	visiting  map[string]bool
+}
+
+// Load reads a scene file and the files it includes
+func Load(name string) (*File, error) {
+	d := &decoder{
+		file: &File{
+			Settings: DefaultSettings,
+			Camera:   DefaultCamera,
+		},
+		materials: make(map[string]tracer.Material),
+		visiting:  make(map[string]bool),
+	}
+	if err := d.load(name); err != nil {
+		return nil, err
+	}
+	return d.file, nil
+}
+
+// Resolution returns the width and height of the image,
+// scaled by the Scale percentage
+func (s Settings) Resolution() (w, h int) {
+	w = int(math.Max(1, math.Round(float64(s.Width)*s.Scale/100)))
+	h = int(math.Max(1, math.Round(float64(s.Height)*s.Scale/100)))
+	return
+}
+
+// CropWindow returns the pixels of an image of w by h pixels
+// inside the Crop window, which may hold none
+func (s Settings) CropWindow(w, h int) image.Rectangle {
+	return image.Rect(
+		int(math.Ceil(s.Crop[0]*float64(w))), int(math.Ceil(s.Crop[1]*float64(h))),
+		int(math.Ceil(s.Crop[2]*float64(w))), int(math.Ceil(s.Crop[3]*float64(h))),
+	)
+}
+
+// ParseCrop reads a crop window given as "xmin,ymin,xmax,ymax"
+func ParseCrop(str string) (crop [4]float64, err error) {
+	parts := strings.Split(str, ",")
+	if len(parts) != 4 {
+		return crop, fmt.Errorf("crop window %q is not xmin,ymin,xmax,ymax", str)
+	}
+	for i, p := range parts {
+		if crop[i], err = strconv.ParseFloat(strings.TrimSpace(p), 64); err != nil {
+			return crop, fmt.Errorf("crop window %q is not xmin,ymin,xmax,ymax", str)
+		}
+	}
+	if !validCrop(crop) {
+		return crop, fmt.Errorf("crop window %q is out of the [0, 1] range or empty", str)
+	}
+	return crop, nil
+}
+
+func validCrop(c [4]float64) bool {
+	return 0 <= c[0] && c[0] < c[2] && c[2] <= 1 && 0 <= c[1] && c[1] < c[3] && c[3] <= 1
+}
+
+// Scene builds a tracer.Scene from the file, with photon maps
+// sized after the photons setting. The camera has the aspect
+// ratio of the image.
+func (f *File) Scene() (scene tracer.Scene, err error) {
+	w, h := f.Settings.Resolution()
+	crop := f.Settings.CropWindow(w, h)
+	if crop.Empty() {
+		return scene, fmt.Errorf("the crop window holds no pixel of the %dx%d image", w, h)
+	}
+	cam, err := f.Camera.Camera(float64(w) / float64(h))
+	if err != nil {
+		return
+	}
+	integrator, err := NewIntegrator(f.Settings.Integrator)
+	if err != nil {
+		return
+	}
+	tonemap, err := NewToneOperator(f.Settings.Tonemap)
+	if err != nil {
+		return
+	}
+	globalMap := tracer.NewPhotonMap(f.Settings.Photons)
+	causticsMap := tracer.NewPhotonMap(f.Settings.Photons / 2)
+	scene = tracer.NewScene(w, h, cam, f.Objects, &globalMap, &causticsMap)
+	scene.Crop = crop
+	scene.MaxDepth = f.Settings.MaxDepth
+	scene.Integrator = integrator
+	scene.Tonemap = tracer.ToneMapping{Operator: tonemap, Exposure: f.Settings.Exposure, White: f.Settings.White}
+	return scene, nil
+}
+
+// parse reads a YAML or JSON document into its root node
+func parse(name string) (node, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return node{}, err
+	}
+	// JSON is valid YAML, but encoding/json gives better syntax errors
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			if serr, ok := err.(*json.SyntaxError); ok {
+				line := 1 + strings.Count(string(data[:serr.Offset]), "\n")
+				return node{}, &Error{File: name, Line: line, Msg: serr.Error()}
+			}
+			return node{}, &Error{File: name, Msg: err.Error()}
+		}
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return node{}, &Error{File: name, Msg: strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	if len(doc.Content) == 0 {
+		return node{}, &Error{File: name, Msg: "empty scene file"}
+	}
+	return node{n: doc.Content[0], file: name}, nil
+}
+
+// load reads a scene file, processing its includes first so
+// that the including file can override their settings
+func (d *decoder) load(name string) error {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	if d.visiting[abs] {
+		return fmt.Errorf("%s: include cycle", name)
+	}
+	d.visiting[abs] = true
+	defer delete(d.visiting, abs)
+
+	root, err := parse(name)
+	if err != nil {
+		return err
+	}
+	doc, err := root.object("include", "render", "camera", "materials", "shapes", "lights")
+	if err != nil {
+		return err
+	}
+
+	if inc, ok := doc.fields["include"]; ok {
+		files, err := inc.listOrOne()
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			path, err := f.str()
+			if err != nil {
+				return err
+			}
+			if err := d.load(filepath.Join(filepath.Dir(name), filepath.FromSlash(path))); err != nil {
+				return err
+			}
+		}
+	}
+	if n, ok := doc.fields["render"]; ok {
+		if err := d.render(n); err != nil {
+			return err
+		}
+	}
+	if n, ok := doc.fields["camera"]; ok {
+		if err := d.camera(n); err != nil {
+			return err
+		}
+	}
+	if n, ok := doc.fields["materials"]; ok {
+		if err := d.materialLibrary(n); err != nil {
+			return err
+		}
+	}
+	for _, section := range []string{"shapes", "lights"} {
+		n, ok := doc.fields[section]
+		if !ok {
+			continue
+		}
+		entries, err := n.list()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			objs, err := d.shape(e, section == "lights")
+			if err != nil {
+				return err
+			}
+			d.file.Objects = append(d.file.Objects, objs...)
+		}
+	}
+	return nil
+}
+
+func (d *decoder) render(n node) error {
+	o, err := n.object("width", "height", "scale", "crop", "samples", "photons", "depth", "integrator", "tonemap", "exposure", "white")
+	if err != nil {
+		return err
+	}
+	s := &d.file.Settings
+	if s.Integrator, err = o.str("integrator", s.Integrator); err != nil {
+		return err
+	}
+	if s.Tonemap, err = o.str("tonemap", s.Tonemap); err != nil {
+		return err
+	}
+	if s.Exposure, err = o.float("exposure", s.Exposure); err != nil {
+		return err
+	}
+	if s.White, err = o.float("white", s.White); err != nil {
+		return err
+	}
+	if s.Scale, err = o.float("scale", s.Scale); err != nil {
+		return err
+	}
+	if f, ok := o.fields["crop"]; ok {
+		l, err := f.list()
+		if err != nil || len(l) != 4 {
+			return f.errorf("expected a list of 4 numbers: xmin, ymin, xmax and ymax")
+		}
+		for i, c := range l {
+			if s.Crop[i], err = c.float(); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range []struct {
+		key string
+		val *int
+	}{
+		{"width", &s.Width},
+		{"height", &s.Height},
+		{"samples", &s.Samples},
+		{"photons", &s.Photons},
+		{"depth", &s.MaxDepth},
+	} {
+		if *f.val, err = o.int(f.key, *f.val); err != nil {
+			return err
+		}
+	}
+	return o.settingError(s.Validate())
+}
+
+// SettingError is an invalid render or camera setting
+type SettingError struct {
+	Key string // field of the render or camera section, if any
+	Msg string
+}
+
+func (e *SettingError) Error() string {
+	if e.Key == "" {
+		return e.Msg
+	}
+	return e.Key + ": " + e.Msg
+}
+
+// settingError points a SettingError at the field of o holding it
+func (o object) settingError(err error) error {
+	se, ok := err.(*SettingError)
+	if !ok {
+		return err
+	}
+	if f, ok := o.fields[se.Key]; ok {
+		return f.errorf("%s", se.Msg)
+	}
+	return o.errorf("%v", se)
+}
+
+// Validate checks the render settings, returning a SettingError
+func (s Settings) Validate() error {
+	if _, err := NewIntegrator(s.Integrator); err != nil {
+		return &SettingError{"integrator", fmt.Sprintf("%v (expected one of %s)", err, strings.Join(Integrators, ", "))}
+	}
+	if _, err := NewToneOperator(s.Tonemap); err != nil {
+		return &SettingError{"tonemap", fmt.Sprintf("%v (expected one of %s)", err, strings.Join(Tonemaps, ", "))}
+	}
+	if s.White < 0 {
+		return &SettingError{"white", "must not be negative"}
+	}
+	if s.Scale <= 0 {
+		return &SettingError{"scale", "must be positive"}
+	}
+	if !validCrop(s.Crop) {
+		return &SettingError{"crop", "crop window out of the [0, 1] range or empty"}
+	}
+	if s.Photons < 0 {
+		return &SettingError{"photons", "must not be negative"}
+	}
+	for _, f := range []struct {
+		key string
+		val int
+	}{
+		{"width", s.Width},
+		{"height", s.Height},
+		{"samples", s.Samples},
+		{"depth", s.MaxDepth},
+	} {
+		if f.val <= 0 {
+			return &SettingError{f.key, "must be positive"}
+		}
+	}
+	return nil
+}
+
+func (d *decoder) camera(n node) (err error) {
+	o, err := n.object("type", "eye", "lookat", "up", "fov", "aperture", "focus")
+	if err != nil {
+		return err
+	}
+	c := &d.file.Camera
+	if c.Type, err = o.str("type", c.Type); err != nil {
+		return
+	}
+	if c.Eye, err = o.vec3("eye", c.Eye); err != nil {
+		return
+	}
+	if c.LookAt, err = o.vec3("lookat", c.LookAt); err != nil {
+		return
+	}
+	if c.Up, err = o.vec3("up", c.Up); err != nil {
+		return
+	}
+	if c.FOV, err = o.float("fov", c.FOV); err != nil {
+		return
+	}
+	if c.Aperture, err = o.float("aperture", c.Aperture); err != nil {
+		return
+	}
+	if f, ok := o.fields["focus"]; ok {
+		// The focus distance is a number or "auto"
+		if s, _ := f.str(); s == "auto" {
+			c.Focus = 0
+		} else if c.Focus, err = f.float(); err != nil {
+			return f.errorf("expected a distance or \"auto\"")
+		} else if c.Focus <= 0 {
+			return f.errorf("must be positive")
+		}
+	}
+	return o.settingError(c.Validate())
+}
@@ -0,0 +1,33 @@
+package scenefile
+
+import (
+	"fmt"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/tracer"
+)
+
+// Tonemaps lists the supported tone mapping operators
+var Tonemaps = []string{
+	"linear",
+	"reinhard",
+	"reinhard-extended",
+	"hable",
+	"aces",
+}
+
+// NewToneOperator returns the tone mapping operator with the given name
+func NewToneOperator(name string) (tracer.ToneOperator, error) {
+	switch name {
+	case "linear":
+		return tracer.LinearTone, nil
+	case "reinhard":
+		return tracer.ReinhardTone, nil
+	case "reinhard-extended":
+		return tracer.ExtendedReinhardTone, nil
+	case "hable":
+		return tracer.HableTone, nil
+	case "aces":
+		return tracer.ACESTone, nil
+	}
+	return 0, fmt.Errorf("unknown tone mapping %q", name)
+}
@@ -2,6 +2,7 @@ package tracer
 
 import (
 	"math"
+	"math/rand"
 
 	"github.com/gabrielfvale/go-raytracer/pkg/geom"
 )
@@ -20,28 +21,140 @@ func NewAABB(min, max geom.Vec3, mat Material) AABB {
 	return AABB{MinBound: min, MaxBound: max, Center: center, Mat: mat}
 }
 
-// Hit checks if a Ray hit the sphere, returning
-// t, p (point in ray) and n (surface normal)
-func (aabb AABB) Hit(r geom.Ray, tMin, tMax float64) (t float64, surf Surface) {
+// Hit checks if a Ray hit the box using the slab method, returning
+// the hit record of the entry face, or of the exit face when the
+// ray starts inside the box
+func (aabb AABB) Hit(r geom.Ray, tMin, tMax float64) (rec HitRecord, hit bool) {
 	minB, maxB := aabb.MinBound, aabb.MaxBound
-	n := minB.Minus(r.Orig).Div(r.Dir)
-	f := maxB.Minus(r.Orig).Div(r.Dir)
-	n, f = n.Min(f), n.Max(f)
-	t0 := math.Max(math.Max(n.X(), n.Y()), n.Z())
-	t1 := math.Min(math.Min(f.X(), f.Y()), f.Z())
-
-	ltMin, ltMax := tMin, tMax
-	if t0 > ltMin {
-		ltMin = t0
+	t0, t1 := math.Inf(-1), math.Inf(1)
+	axis0, axis1 := 0, 0
+	for a := 0; a < 3; a++ {
+		inv := 1.0 / r.Dir.E[a]
+		n := (minB.E[a] - r.Orig.E[a]) * inv
+		f := (maxB.E[a] - r.Orig.E[a]) * inv
+		if n > f {
+			n, f = f, n
+		}
+		if n > t0 {
+			t0, axis0 = n, a
+		}
+		if f < t1 {
+			t1, axis1 = f, a
+		}
 	}
-	if t1 < ltMax {
-		ltMax = t1
+	if t1 < t0 {
+		return
 	}
 
-	if ltMax <= ltMin {
-		return -1.0, aabb
+	// The outward normal of the entry face points against the ray,
+	// the one of the exit face points along it
+	t, axis, sign := t0, axis0, -1.0
+	if t <= tMin || t >= tMax {
+		t, axis, sign = t1, axis1, 1.0
+		if t <= tMin || t >= tMax {
+			return
+		}
 	}
-	return t0, aabb
+	if r.Dir.E[axis] < 0.0 {
+		sign = -sign
+	}
+
+	rec.T = t
+	rec.P = r.At(t)
+	var n geom.Vec3
+	n.E[axis] = sign
+	rec.SetFaceNormal(r, n, n)
+
+	// Face coordinates span the two other axes
+	ua, va := (axis+1)%3, (axis+2)%3
+	d := maxB.Minus(minB)
+	rec.U = (rec.P.E[ua] - minB.E[ua]) / d.E[ua]
+	rec.V = (rec.P.E[va] - minB.E[va]) / d.E[va]
+	rec.DPdu.E[ua] = d.E[ua]
+	rec.DPdv.E[va] = d.E[va]
+	rec.Mat = aabb.Mat
+	return rec, true
+}
+
+// visibleFaces returns the outward normals and areas of the faces
+// of the box turned towards p, zero for the other faces
+func (a AABB) visibleFaces(p geom.Vec3) (faces [3]geom.Vec3, areas [3]float64, total float64) {
+	d := a.MaxBound.Minus(a.MinBound)
+	for axis := 0; axis < 3; axis++ {
+		switch {
+		case p.E[axis] < a.MinBound.E[axis]:
+			faces[axis].E[axis] = -1
+		case p.E[axis] > a.MaxBound.E[axis]:
+			faces[axis].E[axis] = 1
+		default:
+			continue
+		}
+		areas[axis] = d.E[(axis+1)%3] * d.E[(axis+2)%3]
+		total += areas[axis]
+	}
+	return
+}
+
+// SampleLight chooses a point uniformly on the faces of the box
+// that are turned towards p
+func (a AABB) SampleLight(p geom.Vec3, rnd *rand.Rand) (s LightSample, ok bool) {
+	faces, areas, total := a.visibleFaces(p)
+	if total == 0 {
+		return
+	}
+
+	// Choose a face by its area
+	axis, x := 0, rnd.Float64()*total
+	for axis < 2 && (areas[axis] == 0 || x >= areas[axis]) {
+		x -= areas[axis]
+		axis++
+	}
+	s.N = faces[axis]
+	s.P = a.facePoint(s.N, axis, rnd)
+	return s, s.fromArea(p, 1/total)
+}
+
+// facePoint returns a random point on the face of the
+// box with normal n, perpendicular to axis
+func (a AABB) facePoint(n geom.Vec3, axis int, rnd *rand.Rand) geom.Vec3 {
+	p := a.MinBound
+	if n.E[axis] > 0 {
+		p.E[axis] = a.MaxBound.E[axis]
+	}
+	d := a.MaxBound.Minus(a.MinBound)
+	ua, va := (axis+1)%3, (axis+2)%3
+	p.E[ua] += rnd.Float64() * d.E[ua]
+	p.E[va] += rnd.Float64() * d.E[va]
+	return p
+}
+
+// emitPoint chooses a point uniformly on the surface of the box
+func (a AABB) emitPoint(rnd *rand.Rand) (p, n geom.Vec3) {
+	d := a.MaxBound.Minus(a.MinBound)
+	areas := [3]float64{d.Y() * d.Z(), d.Z() * d.X(), d.X() * d.Y()}
+	axis, x := 0, rnd.Float64()*(areas[0]+areas[1]+areas[2])
+	for axis < 2 && x >= areas[axis] {
+		x -= areas[axis]
+		axis++
+	}
+	n.E[axis] = 1
+	if rnd.Float64() < 0.5 {
+		n.E[axis] = -1
+	}
+	return a.facePoint(n, axis, rnd), n
+}
+
+func (a AABB) emitArea() float64 {
+	d := a.MaxBound.Minus(a.MinBound)
+	return 2 * (d.Y()*d.Z() + d.Z()*d.X() + d.X()*d.Y())
+}
+
+func (a AABB) LightPDF(p geom.Vec3, rec *HitRecord) float64 {
+	_, _, total := a.visibleFaces(p)
+	if total == 0 {
+		return 0
+	}
+	return solidAnglePDF(p, rec.P, rec.Ng, 1/total)
 }
 
 func (a AABB) Material() (m Material) {
@@ -52,21 +165,6 @@ func (a AABB) Pos() (p geom.Vec3) {
 	return a.Center
 }
 
-func (a AABB) Surface(p geom.Vec3) (n geom.Vec3, m Material) {
-	eps := 1e-4
-	switch {
-	case p.X() < a.MinBound.X()+eps:
-		n = geom.NewVec3(-1.0, 0, 0)
-	case p.X() > a.MaxBound.X()-eps:
-		n = geom.NewVec3(1.0, 0, 0)
-	case p.Y() < a.MinBound.Y()+eps:
-		n = geom.NewVec3(0, -1.0, 0)
-	case p.Y() > a.MaxBound.Y()-eps:
-		n = geom.NewVec3(0, 1.0, 0)
-	case p.Z() < a.MinBound.Z()+eps:
-		n = geom.NewVec3(0, 0, -1.0)
-	case p.Z() > a.MaxBound.Z()-eps:
-		n = geom.NewVec3(0, 0, 1.0)
-	}
-	return n, a.Mat
+func (a AABB) Bounds() Bounds {
+	return Bounds{Min: a.MinBound, Max: a.MaxBound}
 }
@@ -0,0 +1,116 @@
+package tracer
+
+import (
+	"math"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Type definition for Bounds, an axis-aligned bounding box
+// without a material, used by acceleration structures
+type Bounds struct {
+	Min, Max geom.Vec3
+}
+
+// EmptyBounds returns Bounds that contain nothing,
+// so that any union with it returns the other operand
+func EmptyBounds() Bounds {
+	inf := math.Inf(1)
+	return Bounds{
+		Min: geom.NewVec3(inf, inf, inf),
+		Max: geom.NewVec3(-inf, -inf, -inf),
+	}
+}
+
+// NewBounds returns the smallest Bounds containing all points
+func NewBounds(points ...geom.Vec3) Bounds {
+	b := EmptyBounds()
+	for _, p := range points {
+		b = b.Extend(p)
+	}
+	return b
+}
+
+// Union returns the Bounds containing both b and b2
+func (b Bounds) Union(b2 Bounds) Bounds {
+	// Plain comparisons are much faster than math.Min/Max,
+	// which matters when building large hierarchies
+	for a := 0; a < 3; a++ {
+		if b2.Min.E[a] < b.Min.E[a] {
+			b.Min.E[a] = b2.Min.E[a]
+		}
+		if b2.Max.E[a] > b.Max.E[a] {
+			b.Max.E[a] = b2.Max.E[a]
+		}
+	}
+	return b
+}
+
+// Extend returns the Bounds containing both b and p
+func (b Bounds) Extend(p geom.Vec3) Bounds {
+	return b.Union(Bounds{Min: p, Max: p})
+}
+
+// Empty returns if the Bounds contain no point
+func (b Bounds) Empty() bool {
+	return b.Min.X() > b.Max.X() || b.Min.Y() > b.Max.Y() || b.Min.Z() > b.Max.Z()
+}
+
+// Diagonal returns the vector from Min to Max
+func (b Bounds) Diagonal() geom.Vec3 {
+	return b.Max.Minus(b.Min)
+}
+
+// Centroid returns the center of the Bounds
+func (b Bounds) Centroid() geom.Vec3 {
+	return b.Min.Plus(b.Max).Scale(0.5)
+}
+
+// SurfaceArea returns the area of the six faces of the Bounds
+func (b Bounds) SurfaceArea() float64 {
+	if b.Empty() {
+		return 0.0
+	}
+	d := b.Diagonal()
+	return 2.0 * (d.X()*d.Y() + d.X()*d.Z() + d.Y()*d.Z())
+}
+
+// MaxExtent returns the index of the longest axis of the Bounds
+func (b Bounds) MaxExtent() int {
+	return maxDim(b.Diagonal())
+}
+
+// Offset returns the position of p relative to the Bounds,
+// 0 at Min and 1 at Max along each axis
+func (b Bounds) Offset(p geom.Vec3) geom.Vec3 {
+	o := p.Minus(b.Min)
+	d := b.Diagonal()
+	for a := 0; a < 3; a++ {
+		if d.E[a] > 0.0 {
+			o.E[a] /= d.E[a]
+		}
+	}
+	return o
+}
+
+// hit checks if a ray, given its origin and inverse direction,
+// overlaps the Bounds within [tMin, tMax]
+func (b Bounds) hit(orig, invDir geom.Vec3, tMin, tMax float64) bool {
+	for a := 0; a < 3; a++ {
+		t0 := (b.Min.E[a] - orig.E[a]) * invDir.E[a]
+		t1 := (b.Max.E[a] - orig.E[a]) * invDir.E[a]
+		if invDir.E[a] < 0.0 {
+			t0, t1 = t1, t0
+		}
+		if t0 > tMin {
+			tMin = t0
+		}
+		if t1 < tMax {
+			tMax = t1
+		}
+		if tMax < tMin {
+			return false
+		}
+	}
+	return true
+}
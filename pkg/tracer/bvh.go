@@ -0,0 +1,278 @@
+package tracer
+
+import (
+	"math"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+const (
+	bvhBuckets     = 12 // number of SAH buckets per split
+	bvhMaxLeafSize = 4  // maximum primitives in a leaf
+	bvhStackSize   = 64 // traversal stack depth kept off the heap
+)
+
+// bvhNode is a node of the flattened tree. Interior nodes store
+// the index of their second child in offset (the first child
+// follows the node), leaves store the index of their first
+// primitive and a non-zero count.
+type bvhNode struct {
+	bounds Bounds
+	offset int32
+	count  uint16
+	axis   uint8
+}
+
+// bvhPrim holds the build information of a primitive
+type bvhPrim struct {
+	index    int
+	bounds   Bounds
+	centroid geom.Vec3
+}
+
+// buildNode is a temporary tree node used before flattening
+type buildNode struct {
+	bounds       Bounds
+	left, right  *buildNode
+	first, count int
+	axis         int
+}
+
+// Type definition for BVH, a bounding volume hierarchy
+// built with the surface area heuristic
+type BVH struct {
+	nodes []bvhNode
+	prims []Hitable
+}
+
+// NewBVH builds a BVH over objects
+func NewBVH(objects []Hitable) *BVH {
+	bvh := &BVH{}
+	if len(objects) == 0 {
+		return bvh
+	}
+	info := make([]bvhPrim, len(objects))
+	for i, o := range objects {
+		bounds := o.Bounds()
+		info[i] = bvhPrim{index: i, bounds: bounds, centroid: bounds.Centroid()}
+	}
+
+	ordered := make([]Hitable, 0, len(info))
+	total := 0
+	root := bvh.build(info, objects, &ordered, &total)
+	bvh.prims = ordered
+	bvh.nodes = make([]bvhNode, 0, total)
+	bvh.flatten(root)
+	return bvh
+}
+
+// build recursively splits info, appending the primitives
+// of each leaf to ordered
+func (bvh *BVH) build(info []bvhPrim, objects []Hitable, ordered *[]Hitable, total *int) *buildNode {
+	*total++
+	node := &buildNode{bounds: EmptyBounds()}
+	centroids := EmptyBounds()
+	for _, p := range info {
+		node.bounds = node.bounds.Union(p.bounds)
+		centroids = centroids.Extend(p.centroid)
+	}
+
+	leaf := func() *buildNode {
+		node.first = len(*ordered)
+		node.count = len(info)
+		for _, p := range info {
+			*ordered = append(*ordered, objects[p.index])
+		}
+		return node
+	}
+
+	n := len(info)
+	axis := centroids.MaxExtent()
+	degenerate := centroids.Max.E[axis] == centroids.Min.E[axis]
+	if n == 1 || (degenerate && n <= math.MaxUint16) {
+		return leaf()
+	}
+
+	mid := n / 2
+	switch {
+	case degenerate:
+		// Too many coincident centroids for a single leaf, split arbitrarily
+	case n <= 2:
+		// Not worth evaluating the SAH, split in the middle
+		partitionMedian(info, axis)
+	default:
+		// Bucket primitives along the axis and compute split costs
+		var counts [bvhBuckets]int
+		var bounds [bvhBuckets]Bounds
+		for i := range bounds {
+			bounds[i] = EmptyBounds()
+		}
+		lo, extent := centroids.Min.E[axis], centroids.Max.E[axis]-centroids.Min.E[axis]
+		bucketOf := func(p bvhPrim) int {
+			b := int(bvhBuckets * (p.centroid.E[axis] - lo) / extent)
+			if b >= bvhBuckets {
+				b = bvhBuckets - 1
+			}
+			return b
+		}
+		for _, p := range info {
+			b := bucketOf(p)
+			counts[b]++
+			bounds[b] = bounds[b].Union(p.bounds)
+		}
+
+		// Sweep from the right to get the cost of every split in linear time
+		var rightArea [bvhBuckets]float64
+		var rightCount [bvhBuckets]int
+		acc, cnt := EmptyBounds(), 0
+		for i := bvhBuckets - 1; i > 0; i-- {
+			acc = acc.Union(bounds[i])
+			cnt += counts[i]
+			rightArea[i], rightCount[i] = acc.SurfaceArea(), cnt
+		}
+		minCost, minBucket := math.Inf(1), 0
+		acc, cnt = EmptyBounds(), 0
+		for i := 0; i < bvhBuckets-1; i++ {
+			acc = acc.Union(bounds[i])
+			cnt += counts[i]
+			leftCost := float64(cnt) * acc.SurfaceArea()
+			rightCost := float64(rightCount[i+1]) * rightArea[i+1]
+			cost := 0.125 + (leftCost+rightCost)/node.bounds.SurfaceArea()
+			if cost < minCost {
+				minCost, minBucket = cost, i
+			}
+		}
+
+		// Create a leaf if splitting costs more than intersecting everything
+		if n <= bvhMaxLeafSize && minCost >= float64(n) {
+			return leaf()
+		}
+
+		mid = 0
+		for i := range info {
+			if bucketOf(info[i]) <= minBucket {
+				info[i], info[mid] = info[mid], info[i]
+				mid++
+			}
+		}
+		if mid == 0 || mid == n {
+			mid = n / 2
+			partitionMedian(info, axis)
+		}
+	}
+
+	node.axis = axis
+	node.left = bvh.build(info[:mid], objects, ordered, total)
+	node.right = bvh.build(info[mid:], objects, ordered, total)
+	return node
+}
+
+// partitionMedian reorders info so that the first half has
+// the smallest centroids along axis
+func partitionMedian(info []bvhPrim, axis int) {
+	lo, hi, k := 0, len(info)-1, len(info)/2
+	for lo < hi {
+		pivot := info[(lo+hi)/2].centroid.E[axis]
+		i, j := lo, hi
+		for i <= j {
+			for info[i].centroid.E[axis] < pivot {
+				i++
+			}
+			for info[j].centroid.E[axis] > pivot {
+				j--
+			}
+			if i <= j {
+				info[i], info[j] = info[j], info[i]
+				i++
+				j--
+			}
+		}
+		if k <= j {
+			hi = j
+		} else if k >= i {
+			lo = i
+		} else {
+			break
+		}
+	}
+}
+
+// flatten appends node and its children to the node array
+// in depth-first order, returning the index of node
+func (bvh *BVH) flatten(node *buildNode) int {
+	index := len(bvh.nodes)
+	bvh.nodes = append(bvh.nodes, bvhNode{bounds: node.bounds})
+	if node.left == nil {
+		bvh.nodes[index].offset = int32(node.first)
+		bvh.nodes[index].count = uint16(node.count)
+		return index
+	}
+	bvh.nodes[index].axis = uint8(node.axis)
+	bvh.flatten(node.left)
+	bvh.nodes[index].offset = int32(bvh.flatten(node.right))
+	return index
+}
+
+// Hit traverses the hierarchy front to back, returning
+// the nearest hit within [tMin, tMax]
+func (bvh *BVH) Hit(r geom.Ray, tMin, tMax float64) (rec HitRecord, hit bool) {
+	closest := tMax
+	if len(bvh.nodes) == 0 {
+		return
+	}
+
+	invDir := geom.NewVec3(1.0/r.Dir.X(), 1.0/r.Dir.Y(), 1.0/r.Dir.Z())
+	// The tree depth is not bounded, so the stack grows
+	// past its initial size for degenerate trees
+	var buf [bvhStackSize]int
+	stack := buf[:0]
+	current := 0
+	for {
+		node := &bvh.nodes[current]
+		if node.bounds.hit(r.Orig, invDir, tMin, closest) {
+			if node.count > 0 {
+				first := int(node.offset)
+				for i := first; i < first+int(node.count); i++ {
+					if hr, ok := bvh.prims[i].Hit(r, tMin, closest); ok {
+						closest, rec, hit = hr.T, hr, true
+					}
+				}
+			} else if invDir.E[node.axis] < 0.0 {
+				// Visit the second child first when the ray goes backwards
+				stack = append(stack, current+1)
+				current = int(node.offset)
+				continue
+			} else {
+				stack = append(stack, int(node.offset))
+				current = current + 1
+				continue
+			}
+		}
+		if len(stack) == 0 {
+			break
+		}
+		current = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+	}
+	return
+}
+
+// Bounds returns the bounds of the root node
+func (bvh *BVH) Bounds() Bounds {
+	if len(bvh.nodes) == 0 {
+		return EmptyBounds()
+	}
+	return bvh.nodes[0].bounds
+}
+
+func (bvh *BVH) Material() (m Material) {
+	return
+}
+
+// Pos returns the center of the hierarchy
+func (bvh *BVH) Pos() (p geom.Vec3) {
+	if len(bvh.nodes) == 0 {
+		return
+	}
+	return bvh.Bounds().Centroid()
+}
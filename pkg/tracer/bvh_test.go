@@ -0,0 +1,113 @@
+package tracer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// benchObjects returns n random spheres and triangles inside a 555 unit box
+func benchObjects(n int, rnd *rand.Rand) []Hitable {
+	mat := LambertMaterial(NewColor(0.5, 0.5, 0.5))
+	point := func() geom.Vec3 {
+		return geom.NewVec3(rnd.Float64()*555, rnd.Float64()*555, rnd.Float64()*555)
+	}
+	objects := make([]Hitable, n)
+	for i := range objects {
+		c := point()
+		if i%2 == 0 {
+			objects[i] = NewSphere(c, 2+rnd.Float64()*8, mat)
+			continue
+		}
+		d1 := geom.SampleSphere(rnd).Scale(10)
+		d2 := geom.SampleSphere(rnd).Scale(10)
+		objects[i] = NewTriangle(c, c.Plus(d1), c.Plus(d2), mat)
+	}
+	return objects
+}
+
+// benchRays returns rays from the front of the box towards random points in it
+func benchRays(n int, rnd *rand.Rand) []geom.Ray {
+	rays := make([]geom.Ray, n)
+	for i := range rays {
+		orig := geom.NewVec3(278, 273, -800)
+		target := geom.NewVec3(rnd.Float64()*555, rnd.Float64()*555, rnd.Float64()*555)
+		rays[i] = geom.NewRay(orig, target.Minus(orig))
+	}
+	return rays
+}
+
+// checkHits compares the BVH of objects against a linear scan
+// for rays from random points of the box in random directions
+func checkHits(t *testing.T, name string, objects []Hitable, rnd *rand.Rand) {
+	tree, list := NewBVH(objects), NewList(objects...)
+	for i := 0; i < 500; i++ {
+		orig := geom.NewVec3(rnd.Float64()*1000-200, rnd.Float64()*1000-200, rnd.Float64()*1000-200)
+		r := geom.NewRay(orig, geom.SampleSphere(rnd))
+		want, wantHit := list.Hit(r, bias, math.MaxFloat64)
+		got, gotHit := tree.Hit(r, bias, math.MaxFloat64)
+		if gotHit != wantHit || (wantHit && got.T != want.T) {
+			t.Fatalf("%s: ray %d hit %v at %g, want %v at %g", name, i, gotHit, got.T, wantHit, want.T)
+		}
+	}
+}
+
+func TestBVHHit(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 3, 5, 17, 100, 1000} {
+		checkHits(t, fmt.Sprintf("%d objects", n), benchObjects(n, rnd), rnd)
+	}
+
+	// Coincident centroids, more than fit in a single leaf
+	mat := LambertMaterial(NewColor(0.5, 0.5, 0.5))
+	var objects []Hitable
+	center := geom.NewVec3(278, 278, 278)
+	for i := 0; i < math.MaxUint16+100; i++ {
+		objects = append(objects, NewSphere(center, 1+rnd.Float64()*200, mat))
+	}
+	checkHits(t, "coincident centroids", objects, rnd)
+
+	// Coincident clusters among scattered objects
+	objects = benchObjects(500, rnd)
+	for i := 0; i < 500; i++ {
+		c := geom.NewVec3(float64(i%3)*100, 300, 300)
+		objects = append(objects, NewSphere(c, 1+rnd.Float64()*20, mat))
+	}
+	checkHits(t, "clusters", objects, rnd)
+
+	// Exponentially spaced objects, split one at a time into a deep tree
+	objects = nil
+	for i := 0; i < 60; i++ {
+		x := math.Pow(1.5, float64(i)) / 1e8
+		objects = append(objects, NewSphere(geom.NewVec3(x, 300, 300), x/10, mat))
+	}
+	checkHits(t, "deep tree", objects, rnd)
+}
+
+func benchmarkHit(b *testing.B, n int, build func([]Hitable) Hitable) {
+	rnd := rand.New(rand.NewSource(1))
+	world := build(benchObjects(n, rnd))
+	rays := benchRays(1024, rnd)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		world.Hit(rays[i%len(rays)], bias, math.MaxFloat64)
+	}
+}
+
+func linear(objects []Hitable) Hitable { return NewList(objects...) }
+func bvh(objects []Hitable) Hitable    { return NewBVH(objects) }
+
+func BenchmarkLinear100(b *testing.B) { benchmarkHit(b, 100, linear) }
+func BenchmarkBVH100(b *testing.B)    { benchmarkHit(b, 100, bvh) }
+func BenchmarkLinear10k(b *testing.B) { benchmarkHit(b, 10000, linear) }
+func BenchmarkBVH10k(b *testing.B)    { benchmarkHit(b, 10000, bvh) }
+func BenchmarkBVHBuild10k(b *testing.B) {
+	objects := benchObjects(10000, rand.New(rand.NewSource(1)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewBVH(objects)
+	}
+}
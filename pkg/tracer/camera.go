@@ -2,38 +2,104 @@ package tracer
 
 import (
 	"math"
+	"math/rand"
 
 	"github.com/gabrielfvale/go-raytracer/pkg/geom"
 )
 
-// Type definition for Camera
-type Camera struct {
+// Camera generates primary rays for image coordinates u, v in [0, 1],
+// with u growing to the right and v growing downwards. Ray returns
+// false for points of the image that the projection does not cover.
+type Camera interface {
+	Ray(u, v float64, rnd *rand.Rand) (r geom.Ray, ok bool)
+}
+
+// focuser is implemented by cameras that can focus on the scene
+type focuser interface {
+	AutoFocus(world Hitable) Camera
+}
+
+// Type definition for PerspectiveCamera
+type PerspectiveCamera struct {
 	origin, horizontal,
 	vertical, lowerLeft geom.Vec3
+	u, v, w      geom.Vec3
+	halfW, halfH float64
+	lensRadius   float64
+	focusDist    float64
+	autofocus    bool
 }
 
-// NewCamera creates a Camera given 3 vectors, fov and aspect ratio
-func NewCamera(eye, lookat, vup geom.Vec3, vfov, aspect float64) (c Camera) {
+// NewPerspectiveCamera creates a thin lens camera given 3 vectors, fov,
+// aspect ratio, aperture radius and focus distance. An aperture of 0
+// gives a pinhole camera, and a focus distance of 0 or less focuses on
+// the object at the center of the image once the camera is given a scene.
+func NewPerspectiveCamera(eye, lookat, vup geom.Vec3, vfov, aspect, aperture, focusDist float64) (c PerspectiveCamera) {
 	theta := vfov * math.Pi / 180
-	halfH := math.Tan(theta / 2)
-	halfW := aspect * halfH
+	c.halfH = math.Tan(theta / 2)
+	c.halfW = aspect * c.halfH
 
-	w := eye.Minus(lookat).Unit()
-	u := vup.Cross(w).Unit()
-	v := u.Cross(w).Unit()
+	c.w = eye.Minus(lookat).Unit()
+	c.u = vup.Cross(c.w).Unit()
+	c.v = c.u.Cross(c.w).Unit()
 
 	c.origin = eye
-	c.lowerLeft = c.origin.Minus(u.Scale(halfW)).Minus(v.Scale(halfH)).Minus(w)
-	c.horizontal = u.Scale(2 * halfW)
-	c.vertical = v.Scale(2 * halfH)
+	c.lensRadius = aperture
+	if focusDist <= 0 {
+		c.autofocus = true
+		focusDist = eye.Minus(lookat).Len()
+	}
+	c.setFocus(focusDist)
 	return
 }
 
-// Ray returns a new Ray using the camera, given
-// u, v coordinates.
-func (c Camera) Ray(u, v float64) geom.Ray {
+// setFocus places the image plane at distance d from the lens
+func (c *PerspectiveCamera) setFocus(d float64) {
+	c.focusDist = d
+	c.lowerLeft = c.origin.Minus(c.u.Scale(c.halfW * d)).Minus(c.v.Scale(c.halfH * d)).Minus(c.w.Scale(d))
+	c.horizontal = c.u.Scale(2 * c.halfW * d)
+	c.vertical = c.v.Scale(2 * c.halfH * d)
+}
+
+// AutoFocus returns the camera focused on the nearest object of world
+// at the center of the image, if the camera was created with autofocus
+func (c PerspectiveCamera) AutoFocus(world Hitable) Camera {
+	if !c.autofocus {
+		return c
+	}
+	center := c.lowerLeft.Plus(c.horizontal.Scale(0.5)).Plus(c.vertical.Scale(0.5))
+	r := geom.NewRay(c.origin, center.Minus(c.origin))
+	if rec, hit := world.Hit(r, bias, math.MaxFloat64); hit {
+		// Distance along the view direction, not along the ray
+		c.setFocus(c.origin.Minus(rec.P).Dot(c.w))
+	}
+	return c
+}
+
+// FocusDist returns the distance from the lens to the plane in focus
+func (c PerspectiveCamera) FocusDist() float64 {
+	return c.focusDist
+}
+
+// Ray returns a new Ray using the camera, given u, v
+// coordinates, starting from a random point on the lens
+func (c PerspectiveCamera) Ray(u, v float64, rnd *rand.Rand) (geom.Ray, bool) {
+	origin := c.origin
+	if c.lensRadius > 0 {
+		rd := geom.SampleDisk(rnd).Scale(c.lensRadius)
+		origin = origin.Plus(c.u.Scale(rd.X())).Plus(c.v.Scale(rd.Y()))
+	}
 	return geom.NewRay(
-		c.origin,
-		c.lowerLeft.Plus(c.horizontal.Scale(u)).Plus(c.vertical.Scale(v)).Minus(c.origin),
-	)
+		origin,
+		c.lowerLeft.Plus(c.horizontal.Scale(u)).Plus(c.vertical.Scale(v)).Minus(origin),
+	), true
+}
+
+// frame returns the right, up and forward unit vectors of a camera
+// at eye looking at lookat
+func frame(eye, lookat, vup geom.Vec3) (right, up, forward geom.Vec3) {
+	forward = lookat.Minus(eye).Unit()
+	right = forward.Cross(vup).Unit()
+	up = right.Cross(forward)
+	return
 }
@@ -49,8 +49,15 @@ func (c Color) Scale(n float64) Color {
 	return Color{Vec3: c.Vec3.Scale(n)}
 }
 
+// Black returns true if all the elements of Color are zero
+func (c Color) Black() bool {
+	return c.R() == 0 && c.G() == 0 && c.B() == 0
+}
+
+// Clamp returns the Color with R, G and B limited to [0, 1]
 func (c Color) Clamp() Color {
-	return NewColor(math.Min(1.0, c.R()), math.Min(1.0, c.G()), math.Min(1.0, c.B()))
+	clamp := func(x float64) float64 { return math.Max(0.0, math.Min(1.0, x)) }
+	return NewColor(clamp(c.R()), clamp(c.G()), clamp(c.B()))
 }
 
 // Gamma raises each of R, G, and B to 1/n
@@ -0,0 +1,74 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Dielectric is a refractive material such as glass or water,
+// optionally absorbing light inside it
+type Dielectric struct {
+	black
+	delta
+	RefrIndex  float64
+	Tint       Color
+	Absorption Color // Beer-Lambert coefficients of the interior
+}
+
+// DielectricMaterial returns a dielectric (refractive) material
+func DielectricMaterial(index float64) Dielectric {
+	return Dielectric{RefrIndex: index, Tint: NewColor(1, 1, 1)}
+}
+
+// TintedDielectricMaterial returns a dielectric material with an absorbing
+// interior, through which light keeps the fraction color of each channel
+// after travelling the given distance
+func TintedDielectricMaterial(index float64, color Color, distance float64) Dielectric {
+	d := Dielectric{RefrIndex: index, Tint: color}
+	for i := range d.Absorption.E {
+		d.Absorption.E[i] = -math.Log(math.Max(color.E[i], 1e-6)) / distance
+	}
+	return d
+}
+
+// refractionRatio returns the ratio of the indices of refraction
+// on each side of the surface, swapping them if the ray is leaving
+// the object
+func (d Dielectric) refractionRatio(rec *HitRecord) float64 {
+	if rec.FrontFace {
+		return 1.0 / d.RefrIndex
+	}
+	return d.RefrIndex
+}
+
+// Sample chooses between reflection and refraction by the Fresnel term
+func (d Dielectric) Sample(rec *HitRecord, wo geom.Vec3, rnd *rand.Rand) (s BSDFSample, ok bool) {
+	incident := wo.Inv()
+	ratio := d.refractionRatio(rec)
+	cosi := math.Min(wo.Dot(rec.N), 1.0)
+	s.Wi = incident.Reflect(rec.N)
+	if rnd.Float64() >= geom.Fresnel(cosi, ratio) {
+		if refracts, dir := incident.Refract(rec.N, ratio); refracts {
+			s.Wi = dir
+		}
+	}
+	s.Weight = NewColor(1, 1, 1)
+	s.Specular = true
+	return s, true
+}
+
+func (d Dielectric) albedo(rec *HitRecord) Color {
+	return d.Tint
+}
+
+// transmittance returns the fraction of light kept after travelling
+// a distance inside the material
+func (d Dielectric) transmittance(dist float64) Color {
+	return NewColor(
+		math.Exp(-d.Absorption.R()*dist),
+		math.Exp(-d.Absorption.G()*dist),
+		math.Exp(-d.Absorption.B()*dist),
+	)
+}
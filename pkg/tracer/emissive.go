@@ -0,0 +1,50 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Emissive is the material of lights, which emit without scattering
+type Emissive struct {
+	delta
+	Color     Color
+	Emittance float64
+}
+
+// LightMaterial returns an emissive material
+func LightMaterial(intensity Color, emittance float64) Emissive {
+	return Emissive{Color: intensity, Emittance: emittance}
+}
+
+func (e Emissive) Emitted(rec *HitRecord, wo geom.Vec3) Color {
+	return e.Color.Scale(e.Emittance)
+}
+
+func (e Emissive) Sample(rec *HitRecord, wo geom.Vec3, rnd *rand.Rand) (s BSDFSample, ok bool) {
+	return s, false
+}
+
+func (e Emissive) albedo(rec *HitRecord) Color {
+	return e.Color
+}
+
+// NormalDebug shows the outward surface normals as colors
+type NormalDebug struct {
+	delta
+}
+
+// NormalMaterial returns a material that shows the surface normals
+func NormalMaterial() NormalDebug {
+	return NormalDebug{}
+}
+
+func (NormalDebug) Emitted(rec *HitRecord, wo geom.Vec3) Color {
+	on := rec.OutwardNormal()
+	return NewColor(on.X()+0.5, on.Y()+0.5, on.Z()+0.5).Scale(0.5)
+}
+
+func (NormalDebug) Sample(rec *HitRecord, wo geom.Vec3, rnd *rand.Rand) (s BSDFSample, ok bool) {
+	return s, false
+}
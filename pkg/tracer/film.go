@@ -0,0 +1,56 @@
+package tracer
+
+import "github.com/gabrielfvale/go-raytracer/pkg/util"
+
+// Film accumulates the linear radiance of the samples taken
+// for every pixel, held row by row from the top left corner
+type Film struct {
+	W, H   int
+	sum    []Color
+	weight []float64
+}
+
+// NewFilm returns an empty Film of w by h pixels
+func NewFilm(w, h int) *Film {
+	return &Film{W: w, H: h, sum: make([]Color, w*h), weight: make([]float64, w*h)}
+}
+
+// Add accumulates the sum c of n samples of pixel x, y
+func (f *Film) Add(x, y int, c Color, n float64) {
+	i := y*f.W + x
+	f.sum[i] = f.sum[i].Plus(c)
+	f.weight[i] += n
+}
+
+// At returns the average radiance of pixel x, y
+func (f *Film) At(x, y int) Color {
+	i := y*f.W + x
+	if f.weight[i] == 0 {
+		return NewColor(0.0, 0.0, 0.0)
+	}
+	return f.sum[i].Scale(1 / f.weight[i])
+}
+
+// Framebuffer converts the film to display colors with tone mapping t
+func (f *Film) Framebuffer(t ToneMapping) *Framebuffer {
+	fb := NewFramebuffer(f.W, f.H)
+	for y := 0; y < f.H; y++ {
+		for x := 0; x < f.W; x++ {
+			fb.Set(x, y, t.Map(f.At(x, y)))
+		}
+	}
+	return fb
+}
+
+// FloatImage returns the linear radiance of the film
+func (f *Film) FloatImage() *util.FloatImage {
+	img := util.NewFloatImage(f.W, f.H)
+	for y := 0; y < f.H; y++ {
+		for x := 0; x < f.W; x++ {
+			c := f.At(x, y)
+			i := 3 * (y*f.W + x)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2] = float32(c.R()), float32(c.G()), float32(c.B())
+		}
+	}
+	return img
+}
@@ -0,0 +1,48 @@
+package tracer
+
+import (
+	"image"
+	"image/color"
+)
+
+// Framebuffer is an 8-bit RGB image in memory, holding the pixels
+// row by row from the top left corner. It implements image.Image.
+type Framebuffer struct {
+	W, H int
+	Pix  []uint8 // red, green and blue of each pixel
+}
+
+// NewFramebuffer returns a black Framebuffer of w by h pixels
+func NewFramebuffer(w, h int) *Framebuffer {
+	return &Framebuffer{W: w, H: h, Pix: make([]uint8, 3*w*h)}
+}
+
+// Set writes the display color c, clamped to [0, 1], to pixel x, y
+func (fb *Framebuffer) Set(x, y int, c Color) {
+	c = c.Clamp()
+	i := 3 * (y*fb.W + x)
+	fb.Pix[i] = uint8(255.99 * c.R())
+	fb.Pix[i+1] = uint8(255.99 * c.G())
+	fb.Pix[i+2] = uint8(255.99 * c.B())
+}
+
+// Stride returns the number of bytes of a row
+func (fb *Framebuffer) Stride() int {
+	return 3 * fb.W
+}
+
+func (fb *Framebuffer) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (fb *Framebuffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, fb.W, fb.H)
+}
+
+func (fb *Framebuffer) At(x, y int) color.Color {
+	if !image.Pt(x, y).In(fb.Bounds()) {
+		return color.RGBA{}
+	}
+	i := 3 * (y*fb.W + x)
+	return color.RGBA{R: fb.Pix[i], G: fb.Pix[i+1], B: fb.Pix[i+2], A: 255}
+}
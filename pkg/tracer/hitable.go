@@ -2,13 +2,55 @@ package tracer
 
 import "github.com/gabrielfvale/go-raytracer/pkg/geom"
 
-type Surface interface {
-	Surface(p geom.Vec3) (n geom.Vec3, m Material)
+// HitRecord holds the surface information at a ray hit.
+// Both normals face against the incoming ray, FrontFace
+// tells if the ray hit the outside of the surface.
+type HitRecord struct {
+	T          float64
+	P          geom.Vec3 // hit point
+	Ng         geom.Vec3 // geometric normal
+	N          geom.Vec3 // shading normal
+	FrontFace  bool
+	U, V       float64   // surface coordinates
+	DPdu, DPdv geom.Vec3 // surface tangents along u and v
+	Mat        Material
+	Light      LightSampler // set when the surface is a scene light
+}
+
+// SetFaceNormal orients the outward normals ng and n against the
+// ray direction, recording which side of the surface was hit
+func (rec *HitRecord) SetFaceNormal(r geom.Ray, ng, n geom.Vec3) {
+	rec.FrontFace = r.Dir.Dot(ng) < 0.0
+	if !rec.FrontFace {
+		ng, n = ng.Inv(), n.Inv()
+	}
+	rec.Ng, rec.N = ng, n
+}
+
+// OutwardNormal returns the shading normal pointing
+// to the outside of the surface
+func (rec *HitRecord) OutwardNormal() geom.Vec3 {
+	if rec.FrontFace {
+		return rec.N
+	}
+	return rec.N.Inv()
 }
 
 // Hitable represents an object that can be hit by a Ray
 type Hitable interface {
-	Hit(r geom.Ray, tMin, tMax float64) (t float64, s Surface)
+	Hit(r geom.Ray, tMin, tMax float64) (rec HitRecord, hit bool)
 	Material() (m Material)
 	Pos() (p geom.Vec3)
+	Bounds() Bounds
+}
+
+// tangents returns two unit vectors perpendicular to n and to each other
+func tangents(n geom.Vec3) (s, t geom.Vec3) {
+	a := geom.NewVec3(1, 0, 0)
+	if n.X() > 0.9 || n.X() < -0.9 {
+		a = geom.NewVec3(0, 1, 0)
+	}
+	s = a.Cross(n).Unit()
+	t = n.Cross(s)
+	return
 }
@@ -0,0 +1,120 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Integrator computes the light arriving at the camera. Preprocess
+// runs once before rendering, and Li is called for every camera ray.
+type Integrator interface {
+	Preprocess(scene *Scene)
+	Li(scene *Scene, r geom.Ray, rnd *rand.Rand) Color
+}
+
+// DirectIntegrator renders direct lighting only, following specular
+// bounces so that mirrors and glass still show the lit scene
+type DirectIntegrator struct{}
+
+func (DirectIntegrator) Preprocess(scene *Scene) {}
+
+func (DirectIntegrator) Li(scene *Scene, r geom.Ray, rnd *rand.Rand) Color {
+	result := NewColor(0.0, 0.0, 0.0)
+	throughput := NewColor(1.0, 1.0, 1.0)
+	for depth := 1; depth < scene.MaxDepth; depth++ {
+		hit, rec := scene.intersect(r, scene.world)
+		if !hit {
+			break
+		}
+		wo := r.Dir.Unit().Inv()
+		throughput = throughput.Times(absorption(r, &rec))
+		result = result.Plus(throughput.Times(rec.Mat.Emitted(&rec, wo)))
+		result = result.Plus(throughput.Times(scene.directLight(&rec, wo, false, rnd)))
+
+		s, ok := rec.Mat.Sample(&rec, wo, rnd)
+		if !ok || !s.Specular {
+			break
+		}
+		throughput = throughput.Times(s.Weight)
+		r = geom.NewRay(rec.P, s.Wi)
+	}
+	return result
+}
+
+// AOIntegrator renders ambient occlusion, the cosine weighted fraction
+// of the hemisphere above a surface that is open up to Radius. A Radius
+// of 0 uses a tenth of the scene size.
+type AOIntegrator struct {
+	Radius float64
+}
+
+func (AOIntegrator) Preprocess(scene *Scene) {}
+
+func (ao AOIntegrator) Li(scene *Scene, r geom.Ray, rnd *rand.Rand) Color {
+	hit, rec := scene.intersect(r, scene.world)
+	if !hit {
+		return NewColor(0.0, 0.0, 0.0)
+	}
+	radius := ao.Radius
+	if radius <= 0 {
+		radius = scene.Bounds().Diagonal().Len() / 10
+	}
+	dir := geom.SampleHemisphereNormal(rec.N, rnd)
+	if _, occluded := scene.world.Hit(geom.NewRay(rec.P, dir), bias, radius); occluded {
+		return NewColor(0.0, 0.0, 0.0)
+	}
+	return NewColor(1.0, 1.0, 1.0)
+}
+
+// DebugView is a surface property shown by DebugIntegrator
+type DebugView int
+
+const (
+	// NormalView shows the outward shading normals
+	NormalView DebugView = iota
+	// DepthView shows the distance to the camera, bright when near
+	DepthView
+	// AlbedoView shows the surface colors, without lighting
+	AlbedoView
+)
+
+// DebugIntegrator shows a property of the surfaces seen by the camera
+type DebugIntegrator struct {
+	View DebugView
+}
+
+func (DebugIntegrator) Preprocess(scene *Scene) {}
+
+func (d DebugIntegrator) Li(scene *Scene, r geom.Ray, rnd *rand.Rand) Color {
+	hit, rec := scene.intersect(r, scene.world)
+	if !hit {
+		return NewColor(0.0, 0.0, 0.0)
+	}
+	switch d.View {
+	case NormalView:
+		n := rec.OutwardNormal()
+		return NewColor(n.X()+1, n.Y()+1, n.Z()+1).Scale(0.5)
+	case DepthView:
+		// Scale the distance between the nearest and farthest
+		// points of the scene bounds
+		b := scene.Bounds()
+		nearest := r.Orig.Max(b.Min).Min(b.Max)
+		var farthest geom.Vec3
+		for i := range farthest.E {
+			farthest.E[i] = b.Min.E[i]
+			if r.Orig.E[i] < b.Centroid().E[i] {
+				farthest.E[i] = b.Max.E[i]
+			}
+		}
+		near, far := nearest.Minus(r.Orig).Len(), farthest.Minus(r.Orig).Len()
+		v := 1 - (rec.P.Minus(r.Orig).Len()-near)/(far-near)
+		v = math.Max(0, math.Min(1, v))
+		return NewColor(v, v, v)
+	}
+	if m, ok := rec.Mat.(albedoMaterial); ok {
+		return m.albedo(&rec)
+	}
+	return rec.Mat.Emitted(&rec, r.Dir.Unit().Inv())
+}
@@ -0,0 +1,70 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Lambert is an ideal diffuse material, optionally textured
+type Lambert struct {
+	black
+	Albedo  Color
+	Texture Texture
+}
+
+// LambertMaterial returns a lambertian material
+func LambertMaterial(albedo Color) Lambert {
+	return Lambert{Albedo: albedo}
+}
+
+// albedo returns the color of the surface at a hit point
+func (l Lambert) albedo(rec *HitRecord) Color {
+	if l.Texture != nil {
+		return l.Texture.Value(rec.U, rec.V)
+	}
+	return l.Albedo
+}
+
+func (l Lambert) Eval(rec *HitRecord, wo, wi geom.Vec3) Color {
+	cos := wi.Dot(rec.N)
+	if cos <= 0 {
+		return Color{}
+	}
+	return l.albedo(rec).Scale(cos / math.Pi)
+}
+
+// Sample chooses a cosine weighted direction around the normal
+func (l Lambert) Sample(rec *HitRecord, wo geom.Vec3, rnd *rand.Rand) (s BSDFSample, ok bool) {
+	s.Wi = geom.SampleHemisphereNormal(rec.N, rnd)
+	if s.Wi.NearZero() {
+		s.Wi = rec.N
+	}
+	s.PDF = math.Max(s.Wi.Dot(rec.N), 0) / math.Pi
+	s.Weight = l.albedo(rec)
+	return s, true
+}
+
+func (l Lambert) PDF(rec *HitRecord, wo, wi geom.Vec3) float64 {
+	return math.Max(wi.Dot(rec.N), 0) / math.Pi
+}
+
+// Diffuse is a lambertian material lit only directly by the scene
+// lights, trading indirect lighting for a noiseless render
+type Diffuse struct {
+	Lambert
+}
+
+// DiffuseMaterial returns a diffuse material
+func DiffuseMaterial(color Color) Diffuse {
+	return Diffuse{Lambert{Albedo: color}}
+}
+
+func (d Diffuse) Sample(rec *HitRecord, wo geom.Vec3, rnd *rand.Rand) (s BSDFSample, ok bool) {
+	return s, false
+}
+
+func (d Diffuse) PDF(rec *HitRecord, wo, wi geom.Vec3) float64 {
+	return 0
+}
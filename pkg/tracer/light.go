@@ -0,0 +1,106 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// LightSample is a point chosen on the surface of a light
+type LightSample struct {
+	P, N geom.Vec3 // point and its normal, facing the shaded point
+	PDF  float64   // density with respect to solid angle at the shaded point
+}
+
+// LightSampler is implemented by shapes that can be sampled as area
+// lights, choosing points on their surface as seen from a point p.
+// LightPDF returns the density of choosing the point of rec, a hit
+// on the light surface, from p.
+type LightSampler interface {
+	SampleLight(p geom.Vec3, rnd *rand.Rand) (s LightSample, ok bool)
+	LightPDF(p geom.Vec3, rec *HitRecord) float64
+}
+
+// fromArea converts a density with respect to the area of the light
+// into one with respect to solid angle seen from p, returning false
+// if the sampled point faces away from p
+func (s *LightSample) fromArea(p geom.Vec3, areaPDF float64) bool {
+	s.PDF = solidAnglePDF(p, s.P, s.N, areaPDF)
+	return s.PDF > 0
+}
+
+// solidAnglePDF converts a density with respect to area at a point q
+// with normal n into a density with respect to solid angle seen from p
+func solidAnglePDF(p, q, n geom.Vec3, areaPDF float64) float64 {
+	d := q.Minus(p)
+	dist2 := d.LenSq()
+	cos := -d.Dot(n) / math.Sqrt(dist2)
+	if cos <= 0 {
+		return 0
+	}
+	return areaPDF * dist2 / cos
+}
+
+// sceneLight wraps a light of the scene, recording in the hit
+// records which light was hit
+type sceneLight struct {
+	Hitable
+	sampler LightSampler
+}
+
+func (l sceneLight) Hit(r geom.Ray, tMin, tMax float64) (rec HitRecord, hit bool) {
+	rec, hit = l.Hitable.Hit(r, tMin, tMax)
+	rec.Light = l.sampler
+	return
+}
+
+// emitter is implemented by lights that emit photons, choosing points
+// uniformly on their surface with the normal of the side they leave.
+// Open surfaces emit from both sides, which emitArea counts.
+type emitter interface {
+	emitPoint(rnd *rand.Rand) (p, n geom.Vec3)
+	emitArea() float64
+}
+
+// emitPhoton returns the ray of a photon leaving light l from a
+// random point of its surface, in a cosine weighted direction
+func emitPhoton(l Hitable, rnd *rand.Rand) (r geom.Ray, ok bool) {
+	e, ok := l.(emitter)
+	if !ok {
+		return
+	}
+	p, n := e.emitPoint(rnd)
+	return geom.NewRay(p, geom.SampleHemisphereNormal(n, rnd)), true
+}
+
+// lightPower returns the flux emitted by light l, which is
+// Lambertian with the radiance of its material
+func lightPower(l Hitable) Color {
+	e, ok := l.(emitter)
+	if !ok {
+		return NewColor(0.0, 0.0, 0.0)
+	}
+	rec := HitRecord{FrontFace: true, Mat: l.Material()}
+	return rec.Mat.Emitted(&rec, geom.Vec3{}).Scale(math.Pi * e.emitArea())
+}
+
+// lightDistribution returns the cumulative power of the scene
+// lights, for choosing lights in proportion to their power
+func (scene Scene) lightDistribution() []float64 {
+	cdf := make([]float64, len(scene.Lights))
+	total := 0.0
+	for i, l := range scene.Lights {
+		p := lightPower(l)
+		total += (p.R() + p.G() + p.B()) / 3
+		cdf[i] = total
+	}
+	return cdf
+}
+
+// powerHeuristic returns the weight of a sample taken with density
+// pdf, against another strategy that would take it with density other
+func powerHeuristic(pdf, other float64) float64 {
+	a, b := pdf*pdf, other*other
+	return a / (a + b)
+}
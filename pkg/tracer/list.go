@@ -11,12 +11,11 @@ func NewList(hl ...Hitable) List {
 	return List{HL: hl}
 }
 
-func (l List) Hit(r geom.Ray, tMin, tMax float64) (t float64, surf Surface) {
+func (l List) Hit(r geom.Ray, tMin, tMax float64) (rec HitRecord, hit bool) {
 	closest := tMax
 	for _, s := range l.HL {
-		if ht, hs := s.Hit(r, tMin, closest); ht > 0.0 {
-			closest, t = ht, ht
-			surf = hs
+		if hr, ok := s.Hit(r, tMin, closest); ok {
+			closest, rec, hit = hr.T, hr, true
 		}
 	}
 	return
@@ -29,3 +28,12 @@ func (l List) Material() (m Material) {
 func (l List) Pos() (p geom.Vec3) {
 	return
 }
+
+// Bounds returns the union of the bounds of the list elements
+func (l List) Bounds() Bounds {
+	b := EmptyBounds()
+	for _, h := range l.HL {
+		b = b.Union(h.Bounds())
+	}
+	return b
+}
@@ -1,41 +1,62 @@
 package tracer
 
-// Material represents a material that produces a scattered ray
-type Material struct {
-	Color        Color
-	RefrIndex    float64
-	Reflectivity float64
-	Roughness    float64
-	Emittance    float64
-	Transparent  bool
-	Lambert      bool
-	Normal       bool
+import (
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Material describes how a surface emits and scatters light. All
+// directions are unit vectors pointing away from the surface, wo
+// towards the viewer and wi towards the incoming light.
+type Material interface {
+	// Emitted returns the radiance emitted towards wo
+	Emitted(rec *HitRecord, wo geom.Vec3) Color
+	// Eval returns the BSDF times the cosine of wi with the normal
+	Eval(rec *HitRecord, wo, wi geom.Vec3) Color
+	// Sample chooses a direction wi to continue a path arriving
+	// from wo, returning false if the surface does not scatter
+	Sample(rec *HitRecord, wo geom.Vec3, rnd *rand.Rand) (s BSDFSample, ok bool)
+	// PDF returns the density with which Sample chooses wi
+	PDF(rec *HitRecord, wo, wi geom.Vec3) float64
 }
 
-func NormalMaterial() Material {
-	return Material{NewColor(0, 0, 0), 1, 0, 0, 0, false, false, true}
+// BSDFSample is a scattering direction chosen by a Material
+type BSDFSample struct {
+	Wi     geom.Vec3
+	Weight Color   // BSDF times cosine divided by the PDF
+	PDF    float64 // zero for specular samples
+	// Specular samples follow a delta distribution, which
+	// Eval and PDF can not reproduce
+	Specular bool
 }
 
-// DiffuseMaterial returns a diffuse material
-func DiffuseMaterial(color Color) Material {
-	return Material{color, 1, 0, 0, 0, false, false, false}
+// black is embedded by materials that do not emit light
+type black struct{}
+
+func (black) Emitted(rec *HitRecord, wo geom.Vec3) Color {
+	return Color{}
 }
 
-// LambertMaterial returns a lambertian material
-func LambertMaterial(albedo Color) Material {
-	return Material{albedo, 1, 0, 0, 0, false, true, false}
+// delta is embedded by materials that only scatter along
+// delta distributions, if at all
+type delta struct{}
+
+func (delta) Eval(rec *HitRecord, wo, wi geom.Vec3) Color {
+	return Color{}
 }
 
-// MetalicMaterial returns a metalic (reflective) material
-func MetalicMaterial(albedo Color, reflectivity, roughness float64) Material {
-	return Material{albedo, 1, reflectivity, roughness, 0, false, false, false}
+func (delta) PDF(rec *HitRecord, wo, wi geom.Vec3) float64 {
+	return 0
 }
 
-// DielectricMaterial returns a dielectric (refractive) material
-func DielectricMaterial(index float64) Material {
-	return Material{NewColor(0, 0, 0), index, 0, 0, 0, true, false, false}
+// absorber is implemented by materials that absorb
+// the light travelling inside them
+type absorber interface {
+	transmittance(dist float64) Color
 }
 
-func LightMaterial(intensity Color, emittance float64) Material {
-	return Material{intensity, 1, 0, 0, emittance, false, false, false}
+// albedoMaterial is implemented by materials with a surface color
+type albedoMaterial interface {
+	albedo(rec *HitRecord) Color
 }
@@ -0,0 +1,91 @@
+package tracer
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Type definition for Mesh
+type Mesh struct {
+	Triangles []Triangle
+	Mat       Material
+	bvh       *BVH
+	areas     []float64 // cumulative triangle areas, for light sampling
+}
+
+// NewMesh returns a Mesh given its triangles, building a BVH over
+// them. The material is applied to every triangle of the mesh.
+func NewMesh(tris []Triangle, mat Material) Mesh {
+	prims := make([]Hitable, len(tris))
+	areas := make([]float64, len(tris))
+	total := 0.0
+	for i := range tris {
+		tris[i].Mat = mat
+		prims[i] = tris[i]
+		total += tris[i].Area()
+		areas[i] = total
+	}
+	return Mesh{Triangles: tris, Mat: mat, bvh: NewBVH(prims), areas: areas}
+}
+
+func (m Mesh) Hit(r geom.Ray, tMin, tMax float64) (rec HitRecord, hit bool) {
+	return m.bvh.Hit(r, tMin, tMax)
+}
+
+// SampleLight chooses a point uniformly on the surface of the mesh,
+// picking a triangle by its area
+func (m Mesh) SampleLight(p geom.Vec3, rnd *rand.Rand) (s LightSample, ok bool) {
+	if len(m.areas) == 0 {
+		return
+	}
+	s.P, s.N = m.triangle(rnd).samplePoint(rnd)
+	if s.N.Dot(p.Minus(s.P)) < 0 {
+		s.N = s.N.Inv()
+	}
+	return s, s.fromArea(p, 1/m.areas[len(m.areas)-1])
+}
+
+// triangle picks a triangle of the mesh by its area
+func (m Mesh) triangle(rnd *rand.Rand) Triangle {
+	total := m.areas[len(m.areas)-1]
+	i := sort.SearchFloat64s(m.areas, rnd.Float64()*total)
+	if i == len(m.areas) {
+		i--
+	}
+	return m.Triangles[i]
+}
+
+// emitPoint chooses a point uniformly on either side of the mesh
+func (m Mesh) emitPoint(rnd *rand.Rand) (p, n geom.Vec3) {
+	return m.triangle(rnd).emitPoint(rnd)
+}
+
+// emitArea counts both sides of the mesh
+func (m Mesh) emitArea() float64 {
+	if len(m.areas) == 0 {
+		return 0
+	}
+	return 2 * m.areas[len(m.areas)-1]
+}
+
+func (m Mesh) LightPDF(p geom.Vec3, rec *HitRecord) float64 {
+	if len(m.areas) == 0 {
+		return 0
+	}
+	return solidAnglePDF(p, rec.P, rec.Ng, 1/m.areas[len(m.areas)-1])
+}
+
+func (m Mesh) Material() Material {
+	return m.Mat
+}
+
+// Pos returns the center of the mesh bounds
+func (m Mesh) Pos() (p geom.Vec3) {
+	return m.bvh.Pos()
+}
+
+func (m Mesh) Bounds() Bounds {
+	return m.bvh.Bounds()
+}
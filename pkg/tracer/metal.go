@@ -0,0 +1,40 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Metal is a reflective material. Rough metals perturb the mirror
+// direction, and are sampled as specular since the perturbation
+// has no closed form density.
+type Metal struct {
+	black
+	delta
+	Albedo       Color
+	Reflectivity float64
+	Roughness    float64
+}
+
+// MetalicMaterial returns a metalic (reflective) material
+func MetalicMaterial(albedo Color, reflectivity, roughness float64) Metal {
+	return Metal{Albedo: albedo, Reflectivity: reflectivity, Roughness: roughness}
+}
+
+func (m Metal) albedo(rec *HitRecord) Color {
+	return m.Albedo
+}
+
+func (m Metal) Sample(rec *HitRecord, wo geom.Vec3, rnd *rand.Rand) (s BSDFSample, ok bool) {
+	reflected := wo.Inv().Reflect(rec.N)
+	// Add roughness/fuzzyness
+	reflected = reflected.Plus(geom.SampleHemisphereNormal(rec.N, rnd).Scale(m.Roughness))
+	if reflected.Dot(rec.N) <= 0 {
+		return s, false
+	}
+	s.Wi = reflected.Unit()
+	s.Weight = m.Albedo.Scale(m.Reflectivity)
+	s.Specular = true
+	return s, true
+}
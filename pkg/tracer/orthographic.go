@@ -0,0 +1,33 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Type definition for OrthographicCamera
+type OrthographicCamera struct {
+	origin, right, up, forward geom.Vec3
+	halfW, halfH               float64
+}
+
+// NewOrthographicCamera creates a parallel projection camera
+// given 3 vectors, the height of the view in world units and
+// the aspect ratio
+func NewOrthographicCamera(eye, lookat, vup geom.Vec3, height, aspect float64) (c OrthographicCamera) {
+	c.origin = eye
+	c.right, c.up, c.forward = frame(eye, lookat, vup)
+	c.halfH = height / 2
+	c.halfW = aspect * c.halfH
+	return
+}
+
+// Ray returns a Ray parallel to the view direction, starting
+// from the point of the view plane at u, v
+func (c OrthographicCamera) Ray(u, v float64, rnd *rand.Rand) (geom.Ray, bool) {
+	x := (2*u - 1) * c.halfW
+	y := (1 - 2*v) * c.halfH
+	origin := c.origin.Plus(c.right.Scale(x)).Plus(c.up.Scale(y))
+	return geom.NewRay(origin, c.forward), true
+}
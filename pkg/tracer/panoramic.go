@@ -0,0 +1,88 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// FisheyeMapping selects how a fisheye lens maps angles to the image
+type FisheyeMapping int
+
+const (
+	// Equidistant maps the angle to the view direction linearly
+	Equidistant FisheyeMapping = iota
+	// Equisolid preserves the solid angle of objects
+	Equisolid
+)
+
+// Type definition for FisheyeCamera
+type FisheyeCamera struct {
+	origin, right, up, forward geom.Vec3
+	maxTheta                   float64
+	aspect                     float64
+	mapping                    FisheyeMapping
+}
+
+// NewFisheyeCamera creates a fisheye camera given 3 vectors, the
+// field of view of the image circle in degrees (up to 360), the
+// aspect ratio and the lens mapping. The image circle touches the
+// top and bottom of the image.
+func NewFisheyeCamera(eye, lookat, vup geom.Vec3, fov, aspect float64, mapping FisheyeMapping) (c FisheyeCamera) {
+	c.origin = eye
+	c.right, c.up, c.forward = frame(eye, lookat, vup)
+	c.maxTheta = math.Min(fov, 360) * math.Pi / 360
+	c.aspect = aspect
+	c.mapping = mapping
+	return
+}
+
+// Ray returns the Ray through the image circle at u, v
+func (c FisheyeCamera) Ray(u, v float64, rnd *rand.Rand) (geom.Ray, bool) {
+	x := (2*u - 1) * c.aspect
+	y := 1 - 2*v
+	r := math.Sqrt(x*x + y*y)
+	if r > 1 {
+		return geom.Ray{}, false
+	}
+
+	var theta float64
+	switch c.mapping {
+	case Equisolid:
+		theta = 2 * math.Asin(r*math.Sin(c.maxTheta/2))
+	default:
+		theta = r * c.maxTheta
+	}
+	phi := math.Atan2(y, x)
+	sinTheta := math.Sin(theta)
+	dir := c.right.Scale(sinTheta * math.Cos(phi)).
+		Plus(c.up.Scale(sinTheta * math.Sin(phi))).
+		Plus(c.forward.Scale(math.Cos(theta)))
+	return geom.NewRay(c.origin, dir), true
+}
+
+// Type definition for EquirectangularCamera
+type EquirectangularCamera struct {
+	origin, right, up, forward geom.Vec3
+}
+
+// NewEquirectangularCamera creates a 360° panoramic camera given
+// 3 vectors. The center of the image looks at lookat, and the image
+// should have an aspect ratio of 2:1.
+func NewEquirectangularCamera(eye, lookat, vup geom.Vec3) (c EquirectangularCamera) {
+	c.origin = eye
+	c.right, c.up, c.forward = frame(eye, lookat, vup)
+	return
+}
+
+// Ray returns the Ray with longitude u and latitude v
+func (c EquirectangularCamera) Ray(u, v float64, rnd *rand.Rand) (geom.Ray, bool) {
+	phi := (u - 0.5) * 2 * math.Pi
+	theta := (0.5 - v) * math.Pi
+	cosTheta := math.Cos(theta)
+	dir := c.right.Scale(cosTheta * math.Sin(phi)).
+		Plus(c.up.Scale(math.Sin(theta))).
+		Plus(c.forward.Scale(cosTheta * math.Cos(phi)))
+	return geom.NewRay(c.origin, dir), true
+}
@@ -0,0 +1,67 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// PathIntegrator is a unidirectional path tracer. At each bounce the
+// lights are sampled directly, and light found by the path itself is
+// weighted against those samples with the power heuristic. Paths are
+// ended by russian roulette once they are a few bounces long.
+type PathIntegrator struct{}
+
+func (PathIntegrator) Preprocess(scene *Scene) {}
+
+func (PathIntegrator) Li(scene *Scene, r geom.Ray, rnd *rand.Rand) Color {
+	result := NewColor(0.0, 0.0, 0.0)
+	throughput := NewColor(1.0, 1.0, 1.0)
+	// Density of the last bounce, zero for specular bounces
+	// and camera rays, which light sampling can not reproduce
+	var lastPDF float64
+	var lastP geom.Vec3
+
+	for depth := 1; depth < scene.MaxDepth; depth++ {
+		hit, rec := scene.intersect(r, scene.world)
+		if !hit {
+			break
+		}
+		wo := r.Dir.Unit().Inv()
+		m := rec.Mat
+		throughput = throughput.Times(absorption(r, &rec))
+
+		if le := m.Emitted(&rec, wo); !le.Black() {
+			w := 1.0
+			if rec.Light != nil && lastPDF > 0 {
+				w = powerHeuristic(lastPDF, rec.Light.LightPDF(lastP, &rec))
+			}
+			result = result.Plus(throughput.Times(le).Scale(w))
+		}
+
+		/* Direct illumination */
+		result = result.Plus(throughput.Times(scene.directLight(&rec, wo, true, rnd)))
+
+		s, ok := m.Sample(&rec, wo, rnd)
+		if !ok || s.Weight.Black() {
+			break
+		}
+		throughput = throughput.Times(s.Weight)
+		lastPDF, lastP = s.PDF, rec.P
+		if s.Specular {
+			lastPDF = 0
+		}
+
+		// Russian roulette
+		if depth >= 3 {
+			q := math.Min(0.95, math.Max(throughput.R(), math.Max(throughput.G(), throughput.B())))
+			if rnd.Float64() >= q {
+				break
+			}
+			throughput = throughput.Scale(1.0 / q)
+		}
+		r = geom.NewRay(rec.P, s.Wi)
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package tracer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// PhotonStats summarizes the contents of a photon map
+type PhotonStats struct {
+	Stored, Max int
+	Power       Color // sum of the photon powers
+	// Minimum, quartiles and maximum of the radius holding
+	// the Nearest photons closest to photons of the map
+	Radius  [5]float64
+	Nearest int
+}
+
+func (s PhotonStats) String() string {
+	return fmt.Sprintf("%d of %d photons, power %.4g %.4g %.4g, radius of %d photons: min %.4g, quartiles %.4g %.4g %.4g, max %.4g",
+		s.Stored, s.Max, s.Power.R(), s.Power.G(), s.Power.B(), s.Nearest,
+		s.Radius[0], s.Radius[1], s.Radius[2], s.Radius[3], s.Radius[4])
+}
+
+// Stats returns the statistics of a balanced photon map, measuring
+// the search radius of nphotons around up to samples of its photons
+func (pmap *PhotonMap) Stats(nphotons, samples int) (s PhotonStats) {
+	s.Stored, s.Max, s.Nearest = pmap.storedPhotons, pmap.maxPhotons, nphotons
+	for i := range pmap.photons {
+		p := &pmap.photons[i]
+		s.Power = s.Power.Plus(NewColor(p.power[0], p.power[1], p.power[2]))
+	}
+	if len(pmap.photons) == 0 || samples <= 0 {
+		return
+	}
+
+	step := int(math.Max(1, float64(len(pmap.photons)/samples)))
+	var radii []float64
+	for i := 0; i < len(pmap.photons); i += step {
+		p := pmap.photons[i]
+		r2 := 0.0
+		for _, q := range pmap.locatePhotons(geom.Vec3{E: p.pos}, 0, nphotons) {
+			r2 = math.Max(r2, dist2(p, *q))
+		}
+		radii = append(radii, math.Sqrt(r2))
+	}
+	sort.Float64s(radii)
+	for i := range s.Radius {
+		s.Radius[i] = radii[i*(len(radii)-1)/4]
+	}
+	return
+}
+
+// brightest returns the largest power component of the photons
+func (pmap *PhotonMap) brightest() float64 {
+	max := 0.0
+	for i := range pmap.photons {
+		p := &pmap.photons[i]
+		max = math.Max(max, math.Max(p.power[0], math.Max(p.power[1], p.power[2])))
+	}
+	return max
+}
+
+// splat returns the color of the power of the photon nearest
+// to pos within radius, scaled to full brightness, or black
+func (pmap *PhotonMap) splat(pos geom.Vec3, radius float64) Color {
+	nearest := pmap.locatePhotons(pos, radius, 1)
+	if len(nearest) == 0 {
+		return NewColor(0.0, 0.0, 0.0)
+	}
+	p := nearest[0]
+	max := math.Max(p.power[0], math.Max(p.power[1], p.power[2]))
+	if max <= 0 {
+		return NewColor(0.0, 0.0, 0.0)
+	}
+	return NewColor(p.power[0], p.power[1], p.power[2]).Scale(1.0 / max)
+}
+
+// WritePLY writes the photons to w as an ASCII PLY point cloud,
+// colored by their power relative to the brightest photon
+func (pmap *PhotonMap) WritePLY(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "ply")
+	fmt.Fprintln(bw, "format ascii 1.0")
+	fmt.Fprintln(bw, "comment photon power relative to the brightest photon")
+	fmt.Fprintf(bw, "element vertex %d\n", len(pmap.photons))
+	for _, prop := range []string{"float x", "float y", "float z", "uchar red", "uchar green", "uchar blue"} {
+		fmt.Fprintln(bw, "property", prop)
+	}
+	fmt.Fprintln(bw, "end_header")
+
+	scale := 0.0
+	if max := pmap.brightest(); max > 0 {
+		scale = 255.0 / max
+	}
+	for i := range pmap.photons {
+		p := &pmap.photons[i]
+		fmt.Fprintf(bw, "%g %g %g %d %d %d\n", p.pos[0], p.pos[1], p.pos[2],
+			uint8(p.power[0]*scale), uint8(p.power[1]*scale), uint8(p.power[2]*scale))
+	}
+	return bw.Flush()
+}
+
+// PhotonMaps returns the global and caustics photon maps
+// of the scene, tracing the photons if they have not been
+func (scene Scene) PhotonMaps() (global, caustics *PhotonMap) {
+	scene.mapPhotons()
+	return scene.globalPmap, scene.causticPmap
+}
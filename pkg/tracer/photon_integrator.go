@@ -0,0 +1,99 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// PhotonIntegrator renders with the photon maps of the scene, following
+// specular bounces up to the first diffuse surface. There the lights are
+// sampled for direct lighting, caustics are read from the caustics map
+// and indirect light is found by final gathering, reading the global
+// map where Gather rays sampled from the surface land. Without gather
+// rays the global map alone is shown. With only one of Global and
+// Caustics set, the irradiance of that map is shown directly, or its
+// photons as dots with the color of their power if Splats is set.
+type PhotonIntegrator struct {
+	Global, Caustics bool
+	Gather           int
+	Splats           bool
+}
+
+// Preprocess traces the photons from the scene lights
+func (PhotonIntegrator) Preprocess(scene *Scene) {
+	scene.mapPhotons()
+}
+
+func (p PhotonIntegrator) Li(scene *Scene, r geom.Ray, rnd *rand.Rand) Color {
+	throughput := NewColor(1.0, 1.0, 1.0)
+	for depth := 1; depth < scene.MaxDepth; depth++ {
+		hit, rec := scene.intersect(r, scene.world)
+		if !hit {
+			break
+		}
+		wo := r.Dir.Unit().Inv()
+		throughput = throughput.Times(absorption(r, &rec))
+
+		if s, ok := rec.Mat.Sample(&rec, wo, rnd); ok && s.Specular {
+			throughput = throughput.Times(s.Weight)
+			r = geom.NewRay(rec.P, s.Wi)
+			continue
+		}
+
+		pmap := scene.globalPmap
+		switch {
+		case p.Global && p.Caustics:
+			result := rec.Mat.Emitted(&rec, wo)
+			if p.Gather > 0 {
+				result = result.Plus(scene.directLight(&rec, wo, false, rnd))
+				result = result.Plus(scene.causticPmap.RadianceEst(&rec, wo, 0, 100))
+				result = result.Plus(p.gather(scene, &rec, wo, rnd))
+			} else {
+				result = result.Plus(scene.globalPmap.RadianceEst(&rec, wo, 0, 100))
+			}
+			return throughput.Times(result)
+		case p.Caustics:
+			pmap = scene.causticPmap
+		}
+		if p.Splats {
+			b := scene.Bounds()
+			return throughput.Times(pmap.splat(rec.P, b.Max.Minus(b.Min).Len()/250))
+		}
+		irrad := pmap.IrradianceEst(rec.P, rec.N, 0, 50)
+		return throughput.Times(Color{Vec3: irrad})
+	}
+	return NewColor(0.0, 0.0, 0.0)
+}
+
+// gather estimates the indirect light at a diffuse surface, averaging
+// the radiance of the global map at the diffuse surfaces reached by
+// rays sampled from it
+func (p PhotonIntegrator) gather(scene *Scene, rec *HitRecord, wo geom.Vec3, rnd *rand.Rand) Color {
+	result := NewColor(0.0, 0.0, 0.0)
+	for i := 0; i < p.Gather; i++ {
+		s, ok := rec.Mat.Sample(rec, wo, rnd)
+		if !ok {
+			break
+		}
+		throughput := s.Weight
+		r := geom.NewRay(rec.P, s.Wi)
+		for depth := 1; depth < scene.MaxDepth; depth++ {
+			hit, grec := scene.intersect(r, scene.world)
+			if !hit {
+				break
+			}
+			gwo := r.Dir.Unit().Inv()
+			throughput = throughput.Times(absorption(r, &grec))
+			if gs, ok := grec.Mat.Sample(&grec, gwo, rnd); ok && gs.Specular {
+				throughput = throughput.Times(gs.Weight)
+				r = geom.NewRay(grec.P, gs.Wi)
+				continue
+			}
+			// Emitted light is left to the direct lighting
+			result = result.Plus(throughput.Times(scene.globalPmap.RadianceEst(&grec, gwo, 0, 100)))
+			break
+		}
+	}
+	return result.Scale(1.0 / float64(p.Gather))
+}
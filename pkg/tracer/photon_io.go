@@ -0,0 +1,149 @@
+package tracer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// photonMagic starts every photon map file, followed by the version
+const photonMagic = "GOPM"
+
+// photonVersion is the version of the photon map format
+const photonVersion = 1
+
+// photonSize is the size in bytes of a stored photon
+const photonSize = 6*8 + 3
+
+// photonPrealloc bounds the photons allocated ahead of reading them
+const photonPrealloc = 1 << 16
+
+// Save writes the photon map to w, in a binary format
+// with a header for the map counts and scaling state
+func (pmap *PhotonMap) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	header := []interface{}{
+		[4]byte{photonMagic[0], photonMagic[1], photonMagic[2], photonMagic[3]},
+		uint32(photonVersion),
+		int64(pmap.maxPhotons),
+		int64(pmap.storedPhotons),
+		int64(pmap.prevScale),
+		pmap.balanced,
+		int64(len(pmap.photons)),
+	}
+	for _, v := range header {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	var buf [photonSize]byte
+	for i := range pmap.photons {
+		p := &pmap.photons[i]
+		for d := 0; d < 3; d++ {
+			binary.LittleEndian.PutUint64(buf[d*8:], math.Float64bits(p.pos[d]))
+			binary.LittleEndian.PutUint64(buf[24+d*8:], math.Float64bits(p.power[d]))
+		}
+		buf[48], buf[49], buf[50] = p.plane, p.theta, p.phi
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// LoadPhotonMap reads a photon map written by Save. Unless r is a
+// bufio.Reader, it is buffered and may be read past the end of the map.
+func LoadPhotonMap(r io.Reader) (pmap PhotonMap, err error) {
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	var version uint32
+	if err = binary.Read(br, binary.LittleEndian, &magic); err != nil {
+		return
+	}
+	if string(magic[:]) != photonMagic {
+		return pmap, errors.New("not a photon map")
+	}
+	if err = binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return pmap, unexpectedEOF(err)
+	}
+	if version != photonVersion {
+		return pmap, fmt.Errorf("unsupported photon map version %d", version)
+	}
+
+	var maxPhotons, storedPhotons, prevScale, n int64
+	var balanced bool
+	for _, v := range []interface{}{&maxPhotons, &storedPhotons, &prevScale, &balanced, &n} {
+		if err = binary.Read(br, binary.LittleEndian, v); err != nil {
+			return pmap, unexpectedEOF(err)
+		}
+	}
+	if n < 0 || n > maxPhotons || maxPhotons > math.MaxInt32 || storedPhotons != n || prevScale < 0 || prevScale > n {
+		return pmap, errors.New("corrupt photon map header")
+	}
+
+	// Allocate as the photons are read, so that a corrupt count
+	// fails at the end of the file instead of exhausting memory
+	pmap = NewPhotonMap(int(math.Min(float64(n), photonPrealloc)))
+	pmap.maxPhotons = int(maxPhotons)
+	pmap.prevScale = int(prevScale)
+	pmap.balanced = balanced
+
+	var buf [photonSize]byte
+	for i := int64(0); i < n; i++ {
+		if _, err = io.ReadFull(br, buf[:]); err != nil {
+			return pmap, unexpectedEOF(err)
+		}
+		pmap.photons = append(pmap.photons, Photon{})
+		p := &pmap.photons[i]
+		for d := 0; d < 3; d++ {
+			p.pos[d] = math.Float64frombits(binary.LittleEndian.Uint64(buf[d*8:]))
+			p.power[d] = math.Float64frombits(binary.LittleEndian.Uint64(buf[24+d*8:]))
+		}
+		if buf[48] > 2 {
+			return pmap, errors.New("corrupt photon map")
+		}
+		p.plane, p.theta, p.phi = buf[48], buf[49], buf[50]
+	}
+	pmap.storedPhotons = len(pmap.photons)
+	return pmap, nil
+}
+
+// unexpectedEOF reports the end of the input inside a photon map
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// SavePhotons writes the global and caustics photon maps of the
+// scene to w, tracing the photons first if they have not been
+func (scene Scene) SavePhotons(w io.Writer) error {
+	global, caustics := scene.PhotonMaps()
+	if err := global.Save(w); err != nil {
+		return err
+	}
+	return caustics.Save(w)
+}
+
+// LoadPhotons reads the photon maps written by SavePhotons,
+// which the photon integrators use instead of tracing photons
+func (scene Scene) LoadPhotons(r io.Reader) error {
+	// Share the buffer, so the caustics are read where the global map ends
+	br := bufio.NewReader(r)
+	global, err := LoadPhotonMap(br)
+	if err != nil {
+		return err
+	}
+	caustics, err := LoadPhotonMap(br)
+	if err != nil {
+		return err
+	}
+	*scene.globalPmap = global
+	*scene.causticPmap = caustics
+	return nil
+}
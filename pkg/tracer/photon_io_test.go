@@ -0,0 +1,90 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// photonHeaderSize is the size in bytes of the photon map header
+const photonHeaderSize = 4 + 4 + 3*8 + 1 + 8
+
+// savedPhotonMap returns a balanced map of n photons and its file
+func savedPhotonMap(t *testing.T, n int) (PhotonMap, []byte) {
+	pmap := randomPhotonMap(n, rand.New(rand.NewSource(1)))
+	pmap.Balance()
+	var buf bytes.Buffer
+	if err := pmap.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return pmap, buf.Bytes()
+}
+
+func TestPhotonMapRoundTrip(t *testing.T) {
+	pmap, data := savedPhotonMap(t, 1000)
+	if len(data) != photonHeaderSize+1000*photonSize {
+		t.Fatalf("saved %d bytes, want %d", len(data), photonHeaderSize+1000*photonSize)
+	}
+	loaded, err := LoadPhotonMap(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.maxPhotons != pmap.maxPhotons || loaded.storedPhotons != pmap.storedPhotons ||
+		loaded.prevScale != pmap.prevScale || loaded.balanced != pmap.balanced {
+		t.Fatalf("loaded header %d %d %d %v, want %d %d %d %v",
+			loaded.maxPhotons, loaded.storedPhotons, loaded.prevScale, loaded.balanced,
+			pmap.maxPhotons, pmap.storedPhotons, pmap.prevScale, pmap.balanced)
+	}
+	if len(loaded.photons) != len(pmap.photons) {
+		t.Fatalf("loaded %d photons, want %d", len(loaded.photons), len(pmap.photons))
+	}
+	for i := range pmap.photons {
+		if loaded.photons[i] != pmap.photons[i] {
+			t.Fatalf("photon %d is %v, want %v", i, loaded.photons[i], pmap.photons[i])
+		}
+	}
+	if loaded.PhotonDir(&loaded.photons[0]) != pmap.PhotonDir(&pmap.photons[0]) {
+		t.Fatal("direction tables differ")
+	}
+}
+
+func TestPhotonMapTruncated(t *testing.T) {
+	_, data := savedPhotonMap(t, 10)
+	for _, n := range []int{0, 3, 4, 8, 20, photonHeaderSize - 1, photonHeaderSize, len(data) - 1} {
+		if _, err := LoadPhotonMap(bytes.NewReader(data[:n])); err == nil {
+			t.Errorf("loaded a map truncated to %d of %d bytes", n, len(data))
+		}
+	}
+}
+
+func TestPhotonMapCorruptHeader(t *testing.T) {
+	_, data := savedPhotonMap(t, 10)
+	for _, c := range []struct {
+		offset int
+		value  int64
+	}{
+		{8, -1},      // maxPhotons
+		{8, 1 << 62}, // maxPhotons
+		{8, 9},       // maxPhotons below the count
+		{16, 11},     // storedPhotons
+		{24, -1},     // prevScale
+		{33, -1},     // count
+		{33, 1 << 40},
+	} {
+		corrupt := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint64(corrupt[c.offset:], uint64(c.value))
+		if _, err := LoadPhotonMap(bytes.NewReader(corrupt)); err == nil {
+			t.Errorf("loaded a map with %d at offset %d", c.value, c.offset)
+		}
+	}
+
+	// A consistent but huge count fails at the end of the file
+	corrupt := append([]byte(nil), data...)
+	for _, offset := range []int{8, 16, 33} {
+		binary.LittleEndian.PutUint64(corrupt[offset:], 1<<30)
+	}
+	if _, err := LoadPhotonMap(bytes.NewReader(corrupt)); err == nil {
+		t.Error("loaded a map of more photons than the file holds")
+	}
+}
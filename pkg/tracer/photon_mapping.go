@@ -4,7 +4,6 @@ import (
 	"math"
 
 	"github.com/gabrielfvale/go-raytracer/pkg/geom"
-	"gonum.org/v1/gonum/spatial/kdtree"
 )
 
 // Photon type definition
@@ -15,24 +14,6 @@ type Photon struct {
 	theta, phi uint8
 }
 
-// Compare satisfies the axis comparisons method of the kdtree.Comparable interface.
-func (p Photon) Compare(c kdtree.Comparable, d kdtree.Dim) float64 {
-	q := c.(Photon)
-	if d > 2 {
-		panic("illegal dimension")
-	}
-	return p.pos[d] - q.pos[d]
-}
-
-// Dims returns the number of dimensions to be considered.
-func (p Photon) Dims() int { return 3 }
-
-// Distance returns the distance between the receiver and c.
-func (p Photon) Distance(c kdtree.Comparable) float64 {
-	q := c.(Photon)
-	return dist2(p, q)
-}
-
 // Dust2 returns the distance between two photons
 func dist2(p, q Photon) float64 {
 	pPos := p.pos
@@ -50,42 +31,15 @@ func dist2(p, q Photon) float64 {
 	return dist2
 }
 
-// photonList is a collection of the place type that satisfies kdtree.Interface.
-type photonList []Photon
-
-func (p photonList) Index(i int) kdtree.Comparable         { return p[i] }
-func (p photonList) Len() int                              { return len(p) }
-func (p photonList) Pivot(d kdtree.Dim) int                { return plane{photonList: p, Dim: d}.Pivot() }
-func (p photonList) Slice(start, end int) kdtree.Interface { return p[start:end] }
-
-// plane is required to help photonList.
-type plane struct {
-	kdtree.Dim
-	photonList
-}
-
-func (p plane) Less(i, j int) bool {
-	d := p.Dim
-	if d > 2 {
-		panic("illegal dimension")
-	}
-	return p.photonList[i].pos[d] < p.photonList[j].pos[d]
-}
-func (p plane) Pivot() int { return kdtree.Partition(p, kdtree.MedianOfMedians(p)) }
-func (p plane) Slice(start, end int) kdtree.SortSlicer {
-	p.photonList = p.photonList[start:end]
-	return p
-}
-func (p plane) Swap(i, j int) {
-	p.photonList[i], p.photonList[j] = p.photonList[j], p.photonList[i]
-}
-
-// PhotonMap type definition
+// PhotonMap type definition. Photons are kept in a flat slice while
+// they are stored, and Balance reorders the slice into a balanced
+// kd-tree for the estimates once all of them have been emitted.
 type PhotonMap struct {
-	photons       *kdtree.Tree
+	photons       []Photon
 	storedPhotons int
 	maxPhotons    int
 	prevScale     int
+	balanced      bool
 
 	costheta [256]float64
 	sintheta [256]float64
@@ -93,13 +47,17 @@ type PhotonMap struct {
 	sinphi   [256]float64
 }
 
-// NewPhotonMap returns a PhotonMap with maxPhotons
+// NewPhotonMap returns a PhotonMap with maxPhotons,
+// holding none when maxPhotons is negative
 func NewPhotonMap(maxPhotons int) (pmap PhotonMap) {
+	if maxPhotons < 0 {
+		maxPhotons = 0
+	}
 	pmap.storedPhotons = 0
 	pmap.prevScale = 0
 	pmap.maxPhotons = maxPhotons
 
-	pmap.photons = kdtree.New(photonList{}, true)
+	pmap.photons = make([]Photon, 0, maxPhotons)
 
 	// initialize direction conversion tables
 	for i := 0; i < 256; i++ {
@@ -125,28 +83,19 @@ func (pmap *PhotonMap) PhotonDir(p *Photon) (dir [3]float64) {
 func (pmap *PhotonMap) IrradianceEst(pos, normal geom.Vec3, radius float64, nphotons int) (irrad geom.Vec3) {
 	irrad = geom.NewVec3(0.0, 0.0, 0.0)
 	point := Photon{pos: pos.E}
-	radius2 := radius * radius
-	useAllPhotons := radius == 0.0
-
-	var keep kdtree.Keeper
-	keep = kdtree.NewNKeeper(nphotons)
-	pmap.photons.NearestSet(keep, point)
+	photons := pmap.locatePhotons(pos, radius, nphotons)
 
 	found := 0
 	// r2 is the squared distance to the nth nearest photon
 	r2 := 0.0
-	for _, c := range keep.(*kdtree.NKeeper).Heap {
-		p := c.Comparable.(Photon)
-		dist2 := p.Distance(point)
-		if useAllPhotons || dist2 < radius2 {
-			pdirf := pmap.PhotonDir(&p)
-			pdir := geom.NewVec3(pdirf[0], pdirf[1], pdirf[2])
-			if pdir.Dot(normal) < 0.0 {
-				flux := geom.NewVec3(p.power[0], p.power[1], p.power[2])
-				irrad = irrad.Plus(flux)
-				r2 = dist2
-				found++
-			}
+	for _, p := range photons {
+		pdirf := pmap.PhotonDir(p)
+		pdir := geom.NewVec3(pdirf[0], pdirf[1], pdirf[2])
+		if pdir.Dot(normal) < 0.0 {
+			flux := geom.NewVec3(p.power[0], p.power[1], p.power[2])
+			irrad = irrad.Plus(flux)
+			r2 = math.Max(r2, dist2(*p, point))
+			found++
 		}
 	}
 
@@ -161,7 +110,51 @@ func (pmap *PhotonMap) IrradianceEst(pos, normal geom.Vec3, radius float64, npho
 	return
 }
 
-// Store puts a photon into the kd-tree
+// coneFilter is the constant k of the cone filter, which weights
+// photons by 1 - d/(k r) for a distance d within the radius r
+const coneFilter = 1.1
+
+// RadianceEst returns the radiance reflected towards wo at a surface,
+// estimated from the nphotons nearest photons within maxDist (0 for
+// no limit) weighted by a cone filter
+func (pmap *PhotonMap) RadianceEst(rec *HitRecord, wo geom.Vec3, maxDist float64, nphotons int) Color {
+	point := Photon{pos: rec.P.E}
+	nearest := pmap.locatePhotons(rec.P, maxDist, nphotons)
+
+	// Keep the photons that arrived on the front of the surface
+	photons := make([]*Photon, 0, len(nearest))
+	r2 := 0.0
+	for _, p := range nearest {
+		pdir := pmap.PhotonDir(p)
+		if geom.NewVec3(pdir[0], pdir[1], pdir[2]).Dot(rec.N) >= 0.0 {
+			continue
+		}
+		photons = append(photons, p)
+		r2 = math.Max(r2, dist2(*p, point))
+	}
+	if len(photons) < 8 {
+		return NewColor(0.0, 0.0, 0.0)
+	}
+
+	r := math.Sqrt(r2)
+	result := NewColor(0.0, 0.0, 0.0)
+	for _, p := range photons {
+		pdir := pmap.PhotonDir(p)
+		wi := geom.NewVec3(-pdir[0], -pdir[1], -pdir[2])
+		// Eval includes the cosine, which the photon flux already accounts for
+		cos := wi.Dot(rec.N)
+		if cos <= 0.0 {
+			continue
+		}
+		f := rec.Mat.Eval(rec, wo, wi).Scale(1.0 / cos)
+		w := 1.0 - math.Sqrt(dist2(*p, point))/(coneFilter*r)
+		result = result.Plus(f.Times(NewColor(p.power[0], p.power[1], p.power[2])).Scale(w))
+	}
+	return result.Scale(1.0 / ((1.0 - 2.0/(3.0*coneFilter)) * math.Pi * r2))
+}
+
+// Store adds a photon to the map, to be put into
+// the kd-tree by the next Balance
 func (pmap *PhotonMap) Store(power, pos, dir [3]float64) {
 	if pmap.storedPhotons >= pmap.maxPhotons {
 		return
@@ -189,21 +182,188 @@ func (pmap *PhotonMap) Store(power, pos, dir [3]float64) {
 	} else {
 		node.phi = uint8(phi)
 	}
-	pmap.photons.Insert(node, true)
+	pmap.photons = append(pmap.photons, node)
+	pmap.balanced = false
+}
+
+// merge appends photons to the map while it holds fewer than max
+func (pmap *PhotonMap) merge(photons []Photon, max int) {
+	if free := max - pmap.storedPhotons; len(photons) > free {
+		photons = photons[:free]
+	}
+	pmap.photons = append(pmap.photons, photons...)
+	pmap.balanced = false
+	pmap.storedPhotons += len(photons)
 }
 
 // ScalePhotonPower is used to scale the power of all
 // photons once they have been emitted from the light source.
+// Only the photons stored since the previous call are scaled.
 func (pmap *PhotonMap) ScalePhotonPower(scale float64) {
-	var newPhotons photonList
-	pmap.photons.Do(func(c kdtree.Comparable, b *kdtree.Bounding, i int) (done bool) {
-		k := c.(Photon)
-		k.power[0] *= scale
-		k.power[1] *= scale
-		k.power[2] *= scale
-		newPhotons = append(newPhotons, k)
+	for i := pmap.prevScale; i < len(pmap.photons); i++ {
+		p := &pmap.photons[i]
+		p.power[0] *= scale
+		p.power[1] *= scale
+		p.power[2] *= scale
+	}
+	pmap.prevScale = len(pmap.photons)
+}
+
+// Balance builds a balanced kd-tree from the stored photons
+func (pmap *PhotonMap) Balance() {
+	balance(pmap.photons)
+	pmap.balanced = true
+}
+
+// balance reorders photons into a kd-tree whose root is the median
+// photon, splitting along the axis in which the photons spread the
+// most, with the subtrees on each side of it
+func balance(photons []Photon) {
+	if len(photons) < 2 {
+		return
+	}
+	min := photons[0].pos
+	max := photons[0].pos
+	for i := range photons {
+		for d := 0; d < 3; d++ {
+			min[d] = math.Min(min[d], photons[i].pos[d])
+			max[d] = math.Max(max[d], photons[i].pos[d])
+		}
+	}
+	axis := uint8(0)
+	for d := uint8(1); d < 3; d++ {
+		if max[d]-min[d] > max[axis]-min[axis] {
+			axis = d
+		}
+	}
+
+	mid := len(photons) / 2
+	medianSplit(photons, mid, axis)
+	photons[mid].plane = axis
+	balance(photons[:mid])
+	balance(photons[mid+1:])
+}
+
+// medianSplit partially sorts photons along axis, so that the
+// photon at k has no greater photons before it and no smaller
+// photons after it
+func medianSplit(photons []Photon, k int, axis uint8) {
+	lo, hi := 0, len(photons)-1
+	for lo < hi {
+		pivot := photons[(lo+hi)/2].pos[axis]
+		i, j := lo, hi
+		for i <= j {
+			for photons[i].pos[axis] < pivot {
+				i++
+			}
+			for photons[j].pos[axis] > pivot {
+				j--
+			}
+			if i <= j {
+				photons[i], photons[j] = photons[j], photons[i]
+				i++
+				j--
+			}
+		}
+		switch {
+		case k <= j:
+			hi = j
+		case k >= i:
+			lo = i
+		default:
+			return
+		}
+	}
+}
+
+// nearestPhotons is a max-heap of the photons
+// found closest to a position
+type nearestPhotons struct {
+	pos   [3]float64
+	max   int
+	dist2 float64 // squared search radius
+	found []*Photon
+	d2    []float64
+}
+
+// locatePhotons returns the nphotons photons nearest to pos within
+// maxDist (0 for no limit)
+func (pmap *PhotonMap) locatePhotons(pos geom.Vec3, maxDist float64, nphotons int) []*Photon {
+	np := nearestPhotons{
+		pos:   pos.E,
+		max:   nphotons,
+		dist2: math.Inf(1),
+		found: make([]*Photon, 0, nphotons),
+		d2:    make([]float64, 0, nphotons),
+	}
+	if maxDist > 0 {
+		np.dist2 = maxDist * maxDist
+	}
+	np.locate(pmap.photons)
+	return np.found
+}
+
+func (np *nearestPhotons) locate(photons []Photon) {
+	if len(photons) == 0 {
+		return
+	}
+	mid := len(photons) / 2
+	p := &photons[mid]
+	delta := np.pos[p.plane] - p.pos[p.plane]
+	near, far := photons[:mid], photons[mid+1:]
+	if delta > 0 {
+		near, far = far, near
+	}
+	np.locate(near)
+	if delta*delta < np.dist2 {
+		np.locate(far)
+	}
+	np.add(p)
+}
+
+// add keeps p if it is within the search radius, dropping the
+// farthest photon when the heap is full
+func (np *nearestPhotons) add(p *Photon) {
+	d2 := dist2(*p, Photon{pos: np.pos})
+	if d2 >= np.dist2 {
 		return
-	})
-	pmap.photons = kdtree.New(newPhotons, true)
-	pmap.prevScale = pmap.storedPhotons + 1
+	}
+	if len(np.found) < np.max {
+		// Sift up
+		np.found = append(np.found, p)
+		np.d2 = append(np.d2, d2)
+		for i := len(np.d2) - 1; i > 0; {
+			parent := (i - 1) / 2
+			if np.d2[parent] >= np.d2[i] {
+				break
+			}
+			np.swap(i, parent)
+			i = parent
+		}
+		if len(np.found) == np.max {
+			np.dist2 = np.d2[0]
+		}
+		return
+	}
+	// Replace the farthest photon and sift down
+	np.found[0], np.d2[0] = p, d2
+	for i := 0; ; {
+		largest := i
+		for _, c := range [2]int{2*i + 1, 2*i + 2} {
+			if c < len(np.d2) && np.d2[c] > np.d2[largest] {
+				largest = c
+			}
+		}
+		if largest == i {
+			break
+		}
+		np.swap(i, largest)
+		i = largest
+	}
+	np.dist2 = np.d2[0]
+}
+
+func (np *nearestPhotons) swap(i, j int) {
+	np.found[i], np.found[j] = np.found[j], np.found[i]
+	np.d2[i], np.d2[j] = np.d2[j], np.d2[i]
 }
@@ -0,0 +1,98 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+const benchPhotons = 1000000
+
+// randomPhotonMap returns a map of n photons scattered on the
+// floor of a unit box, arriving from above
+func randomPhotonMap(n int, rnd *rand.Rand) PhotonMap {
+	pmap := NewPhotonMap(n)
+	for i := 0; i < n; i++ {
+		pos := [3]float64{rnd.Float64(), 0, rnd.Float64()}
+		dir := geom.SampleHemisphereNormal(geom.NewVec3(0, -1, 0), rnd)
+		pmap.Store([3]float64{1, 1, 1}, pos, dir.E)
+	}
+	pmap.ScalePhotonPower(1.0 / float64(n))
+	return pmap
+}
+
+func TestLocatePhotons(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	pmap := NewPhotonMap(2000)
+	for i := 0; i < 2000; i++ {
+		pos := [3]float64{rnd.Float64(), rnd.Float64(), rnd.Float64() / 10}
+		if i%4 == 0 { // coincident photons
+			pos = [3]float64{0.5, 0.5, 0.05}
+		}
+		pmap.Store([3]float64{1, 1, 1}, pos, geom.SampleSphere(rnd).E)
+	}
+	pmap.Balance()
+
+	for i := 0; i < 200; i++ {
+		pos := geom.NewVec3(rnd.Float64(), rnd.Float64(), rnd.Float64()/10)
+		if i%10 == 0 {
+			pos = geom.Vec3{E: pmap.photons[rnd.Intn(len(pmap.photons))].pos}
+		}
+		for _, maxDist := range []float64{0, 0.02, 0.1, 0.5} {
+			for _, n := range []int{1, 8, 50, 600} {
+				// Brute force distances of the nearest photons within maxDist
+				var want []float64
+				for _, p := range pmap.photons {
+					d2 := dist2(p, Photon{pos: pos.E})
+					if maxDist == 0 || d2 < maxDist*maxDist {
+						want = append(want, d2)
+					}
+				}
+				sort.Float64s(want)
+				if len(want) > n {
+					want = want[:n]
+				}
+
+				var got []float64
+				for _, p := range pmap.locatePhotons(pos, maxDist, n) {
+					got = append(got, dist2(*p, Photon{pos: pos.E}))
+				}
+				sort.Float64s(got)
+				if len(got) != len(want) {
+					t.Fatalf("found %d photons around %v within %g, want %d", len(got), pos, maxDist, len(want))
+				}
+				for j := range got {
+					if math.Abs(got[j]-want[j]) > 1e-12 {
+						t.Fatalf("photon %d around %v within %g at distance %g, want %g",
+							j, pos, maxDist, math.Sqrt(got[j]), math.Sqrt(want[j]))
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkPhotonMapBuild(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		pmap := randomPhotonMap(benchPhotons, rnd)
+		b.StartTimer()
+		pmap.Balance()
+	}
+}
+
+func BenchmarkPhotonMapQuery(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	pmap := randomPhotonMap(benchPhotons, rnd)
+	pmap.Balance()
+	n := geom.NewVec3(0, 1, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := geom.NewVec3(rnd.Float64(), 0, rnd.Float64())
+		pmap.IrradianceEst(p, n, 0, 100)
+	}
+}
@@ -1,10 +1,13 @@
 package tracer
 
 import (
+	"image"
 	"log"
 	"math"
 	"math/rand"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gabrielfvale/go-raytracer/pkg/geom"
@@ -17,408 +20,370 @@ const bias = 0.001
 type Scene struct {
 	W, H        int
 	Cam         Camera
+	MaxDepth    int
+	Integrator  Integrator
+	Tonemap     ToneMapping
+	Crop        image.Rectangle // pixels rendered, the whole frame if empty
 	Objects     []Hitable
 	tObjects    []Hitable
 	Lights      []Hitable
-	lightArea   float64
+	world       *BVH
+	tWorld      *BVH
 	globalPmap  *PhotonMap
 	causticPmap *PhotonMap
-	maxDepth    int
 }
 
-type result struct {
-	row    int
-	pixels []byte
-}
-
-// NewScene returns a Scene, given width, height and object slice
+// NewScene returns a Scene, given width, height and object slice.
+// A BVH is built over the objects to accelerate ray queries, and
+// is used to focus the camera if it was created with autofocus.
 func NewScene(width, height int, cam Camera, objects []Hitable, globalPmap *PhotonMap, causticPmap *PhotonMap) Scene {
 	var lights []Hitable
 	var tobjects []Hitable
-	var lightArea float64 = 0.0
-	// pre compute lights and dieletric objects
-	for _, o := range objects {
-		m := o.Material()
-		if m.Emittance > 0 {
+	prims := make([]Hitable, len(objects))
+	// pre compute lights and specular objects
+	for i, o := range objects {
+		prims[i] = o
+		switch o.Material().(type) {
+		case Emissive:
 			lights = append(lights, o)
-			e := o.Material().Color
-			lightArea += e.R() + e.G() + e.B()
-		}
-		// The dielectric objects slice is used for the caustics photon map.
-		if m.Transparent {
+			if l, ok := o.(LightSampler); ok {
+				prims[i] = sceneLight{Hitable: o, sampler: l}
+			}
+		case Dielectric, Metal:
+			// The specular objects slice is used for the caustics photon map,
+			// which holds the light focused by glass and mirrors alike.
 			tobjects = append(tobjects, o)
 		}
 	}
+	world := NewBVH(prims)
+	if f, ok := cam.(focuser); ok {
+		cam = f.AutoFocus(world)
+	}
 	return Scene{
 		W:           width,
 		H:           height,
 		Cam:         cam,
+		MaxDepth:    6,
+		Integrator:  PathIntegrator{},
 		Objects:     objects,
 		tObjects:    tobjects,
 		Lights:      lights,
-		lightArea:   lightArea,
+		world:       world,
+		tWorld:      NewBVH(tobjects),
 		globalPmap:  globalPmap,
 		causticPmap: causticPmap,
-		maxDepth:    6,
 	}
 }
 
-// WriteColor writes a Color to a pixel byte array
-func (scene Scene) WriteColor(index int, pixels []byte, c Color) {
-	r := uint8(255.99 * c.R())
-	g := uint8(255.99 * c.G())
-	b := uint8(255.99 * c.B())
-	pixels[index] = b
-	pixels[index+1] = g
-	pixels[index+2] = r
+// Window returns the pixels of the frame that are rendered,
+// the crop window of the scene or the whole frame
+func (scene Scene) Window() image.Rectangle {
+	frame := image.Rect(0, 0, scene.W, scene.H)
+	if scene.Crop.Empty() {
+		return frame
+	}
+	return scene.Crop.Intersect(frame)
 }
 
-// Render loops over the width and height, and for each sample
-// taking the average of the samples and setting the R, G, B
-// values in a pixel byte array.
-func (scene Scene) Render(pixels []byte, pitch int, samples int) {
+// Render loops over the pixels of the window, and for each pixel
+// adds the samples taken to the film, which may already hold
+// samples of earlier renders of the scene. The film is as large
+// as the window, whose top left pixel is the first of the film.
+func (scene Scene) Render(film *Film, samples int) {
 	log.Printf("Started rendering (%d samples)", samples)
 	start := time.Now()
+	win := scene.Window()
 
-	// scene.mapPhotons()
+	scene.Integrator.Preprocess(&scene)
 
-	bpp := pitch / scene.W // bytes-per-pixel
-	worker := func(jobs <-chan int, results chan<- result, rnd *rand.Rand) {
+	if p, ok := scene.Integrator.(progressive); ok {
+		img := p.render(&scene, samples)
+		for y := 0; y < win.Dy(); y++ {
+			for x := 0; x < win.Dx(); x++ {
+				film.Add(x, y, img[y*win.Dx()+x], 1)
+			}
+		}
+		log.Printf("Rendering took %s", time.Since(start))
+		return
+	}
+	worker := func(jobs <-chan int, done chan<- int, rnd *rand.Rand) {
 		for y := range jobs {
-			res := result{row: y, pixels: make([]byte, bpp*scene.W)}
-			for x := 0; x < scene.W; x++ {
-				ind := (x * bpp)
+			for x := win.Min.X; x < win.Max.X; x++ {
 				c := NewColor(0.0, 0.0, 0.0)
 				for s := 0; s < samples; s++ {
 					u := (float64(x) + rnd.Float64()) / float64(scene.W)
 					v := (float64(y) + rnd.Float64()) / float64(scene.H)
-					r := scene.Cam.Ray(u, v)
-					c = c.Plus(scene.trace(r, 1, rnd))
+					if r, ok := scene.Cam.Ray(u, v, rnd); ok {
+						c = c.Plus(scene.Integrator.Li(&scene, r, rnd))
+					}
 				}
-				c = c.Scale(1 / float64(samples)).Gamma(2)
-				c = c.Clamp()
-				scene.WriteColor(ind, res.pixels, c)
+				film.Add(x-win.Min.X, y-win.Min.Y, c, float64(samples))
 			}
-			results <- res
+			done <- y
 		}
 	}
 
 	workers := runtime.NumCPU() + 1
-	jobs := make(chan int, scene.H)
-	results := make(chan result, workers+1)
-	pending := make(map[int][]byte, 0)
-	cursor := 0
-	bar := util.NewProgress(0, scene.H)
+	jobs := make(chan int, win.Dy())
+	done := make(chan int, workers+1)
+	bar := util.NewProgress(0, win.Dy())
 
+	seed := time.Now().UnixNano()
 	for w := 0; w < workers; w++ {
-		go worker(jobs, results, rand.New(rand.NewSource(time.Now().Unix())))
+		go worker(jobs, done, rand.New(rand.NewSource(seed+int64(w))))
 	}
-	for y := 0; y < scene.H; y++ {
+	for y := win.Min.Y; y < win.Max.Y; y++ {
 		jobs <- y
 	}
 
 	close(jobs)
 
 	log.Printf("Rendering scene")
-	for y := 0; y < scene.H; y++ {
-		r := <-results
+	for y := 0; y < win.Dy(); y++ {
+		<-done
 		bar.Tick()
-		pending[r.row] = r.pixels
-		for len(pending[cursor]) > 0 {
-			copy(pixels[cursor*pitch:], pending[cursor])
-			delete(pending, cursor)
-			cursor++
-		}
 	}
 
 	elapsed := time.Since(start)
 	log.Printf("Rendering took %s", elapsed)
 }
 
-// mapPhotons takes the two photons maps from the scene
-// and runs the photon mapping routines for each one
+// emitLimit bounds the photons emitted per photon stored, so that
+// lights which reach no diffuse surface do not stall photon mapping
+const emitLimit = 1000
+
+// mapPhotons emits photons from every light of the scene into the
+// global and caustics maps, each light filling a share of them in
+// proportion to its power
 func (scene Scene) mapPhotons() {
-	rnd1 := rand.New(rand.NewSource(time.Now().Unix()))
 	global := scene.globalPmap
 	caustics := scene.causticPmap
+	nlights := len(scene.Lights)
+	if global.balanced && caustics.balanced { // mapped or loaded already
+		return
+	}
 
 	log.Printf("Tracing photons")
-	for _, l := range scene.Lights {
-		e := l.Material().Color
-		area := e.R() + e.G() + e.B()
-		pos := l.Pos()
-		nl := geom.NewVec3(0, -1, 0)
-		log.Printf("Global photon mapping")
-		for global.storedPhotons < global.maxPhotons*int(scene.lightArea/area) {
-			rp := geom.NewRay(pos, geom.SampleHemisphereNormal(nl, rnd1))
-			scene.tracePhotons(rp, 1, NewColor(15.0, 15.0, 15.0), global, false, rnd1)
-		}
-		log.Printf("Caustics photon mapping")
-		for caustics.storedPhotons < caustics.maxPhotons*int(scene.lightArea/area) {
-			rp := geom.NewRay(pos, geom.SampleHemisphereNormal(nl, rnd1))
-			scene.tracePhotons(rp, 1, NewColor(1.0, 1.0, 1.0), caustics, true, rnd1)
-		}
+	cdf := scene.lightDistribution()
+	if nlights == 0 || cdf[nlights-1] <= 0 {
+		log.Printf("No lights emit photons")
+		nlights = 0
 	}
-	// Scale photon power
-	global.ScalePhotonPower(1000.0 / float64(global.maxPhotons))
-	caustics.ScalePhotonPower(1000.0 / float64(caustics.maxPhotons))
-}
-
-// Intersect loops over a list of Hitable, returning if there was a hit,
-// the nearest t and the surface hit s
-func (scene Scene) intersect(r geom.Ray, objs []Hitable) (hit bool, t float64, s Surface) {
-	tMin, tMax := bias, math.MaxFloat64
-	t = tMax
-	hit = false
-	for _, o := range objs {
-		if ht, hs := o.Hit(r, tMin, t); ht > 0.0 {
-			hit = true
-			t = ht
-			s = hs
+	for i, l := range scene.Lights[:nlights] {
+		share := cdf[i] / cdf[nlights-1]
+		log.Printf("Global photon mapping (light %d of %d)", i+1, nlights)
+		scene.emitPhotons(l, global, int(share*float64(global.maxPhotons)), false)
+		if len(scene.tObjects) == 0 {
+			continue
 		}
+		log.Printf("Caustics photon mapping (light %d of %d)", i+1, nlights)
+		scene.emitPhotons(l, caustics, int(share*float64(caustics.maxPhotons)), true)
 	}
-	return
+	log.Printf("Stored %d global and %d caustic photons", global.storedPhotons, caustics.storedPhotons)
+	global.Balance()
+	caustics.Balance()
 }
 
-// Irradiance traces a ray, and estimates a color given a photon map.
-func (scene Scene) irradiance(pmap *PhotonMap, r geom.Ray, depth int, rnd *rand.Rand) Color {
-
-	black := NewColor(0.0, 0.0, 0.0)
-	if depth >= scene.maxDepth {
-		return black
+// emitPhotons traces photons from light l until pmap holds quota
+// photons, sharing the power of the light among the photons emitted.
+// The photons are traced by a worker per CPU, each with its own
+// random stream and buffer, and the buffers are merged at the end.
+func (scene Scene) emitPhotons(l Hitable, pmap *PhotonMap, quota int, caustics bool) {
+	need := quota - pmap.storedPhotons
+	if need <= 0 {
+		return
 	}
-
-	hit, tNear, surf := scene.intersect(r, scene.Objects)
-
-	if !hit {
-		return black
+	power := lightPower(l)
+	limit := int64(emitLimit * need)
+
+	// Every stored photon is tagged with the number of its emission
+	var stored, emitted int64
+	worker := func(buf *PhotonMap, emissions *[]int64, rnd *rand.Rand) {
+		for atomic.LoadInt64(&stored) < int64(need) {
+			e := atomic.AddInt64(&emitted, 1)
+			if e > limit {
+				return
+			}
+			n := buf.storedPhotons
+			if rp, ok := emitPhoton(l, rnd); ok {
+				scene.tracePhotons(rp, 1, power, buf, caustics, rnd)
+			}
+			for i := n; i < buf.storedPhotons; i++ {
+				*emissions = append(*emissions, e)
+			}
+			atomic.AddInt64(&stored, int64(buf.storedPhotons-n))
+		}
 	}
 
-	incident := r.Dir.Unit()
-	p := r.At(tNear)
-	n, m := surf.Surface(p)
-	orientedN := n
-	if n.Dot(incident) >= 0.0 {
-		orientedN = orientedN.Inv()
+	workers := runtime.NumCPU() + 1
+	buffers := make([]PhotonMap, workers)
+	emissions := make([][]int64, workers)
+	seed := time.Now().UnixNano()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		buffers[w].maxPhotons = need
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			worker(&buffers[w], &emissions[w], rand.New(rand.NewSource(seed+int64(w))))
+		}(w)
 	}
-	// BRDF modulator
-	f := m.Color
-
-	if m.Reflectivity > 0 { // Metalic material
-		reflected := incident.Reflect(n)
-		// Add roughness/fuzzyness
-		reflected = reflected.Plus(geom.SampleHemisphereNormal(n, rnd).Scale(m.Roughness))
-		if reflected.Dot(n) > 0 {
-			r2 := geom.NewRay(p, reflected)
-			return f.Times(scene.irradiance(pmap, r2, depth+1, rnd))
+	wg.Wait()
+
+	// Workers may overshoot together. Keep the photons of the first
+	// emissions up to the quota, merging the buffers in emission order,
+	// and share the power among the emissions they come from.
+	photons := make([]Photon, 0, need)
+	next := make([]int, workers)
+	var last int64
+	for len(photons) < need {
+		first := -1
+		for w := range buffers {
+			if next[w] < len(emissions[w]) && (first < 0 || emissions[w][next[w]] < emissions[first][next[first]]) {
+				first = w
+			}
 		}
-	} else if m.Transparent { // Dielectric material
-		etai, etat := 1.0, m.RefrIndex
-		refrRatio := etai / etat
-
-		refracts, rayDir := incident.Refract(n, refrRatio)
-		if !refracts {
-			rayDir = incident.Reflect(n)
+		if first < 0 {
+			break
 		}
-		r2 := geom.NewRay(p, rayDir)
-		return scene.irradiance(pmap, r2, depth+1, rnd)
-	} else {
-		// Material is diffuse
-		// Direct visualization of photon map
-		irradVec := pmap.IrradianceEst(p, n, 0, 100)
-		return NewColor(irradVec.X(), irradVec.Y(), irradVec.Z())
+		photons = append(photons, buffers[first].photons[next[first]])
+		last = emissions[first][next[first]]
+		next[first]++
 	}
-	return black
-}
-
-// trace checks if a ray intersects a list of objects,
-// returning their color. If there is no hit,
-// returns a black background
-func (scene Scene) trace(r geom.Ray, depth int, rnd *rand.Rand) Color {
-	if depth >= scene.maxDepth {
-		return NewColor(0.0, 0.0, 0.0)
+	if len(photons) < need { // every emission is kept
+		last = emitted
+		if last > limit {
+			last = limit
+		}
 	}
+	pmap.merge(photons, quota)
+	pmap.ScalePhotonPower(1.0 / float64(last))
+}
 
-	hit, tNear, surf := scene.intersect(r, scene.Objects)
+// Bounds returns the world-space bounding box of all scene objects
+func (scene Scene) Bounds() Bounds {
+	return scene.world.Bounds()
+}
 
-	if !hit {
-		// t := 0.5 * (r.Dir.Y() + 1.0)
-		// c1 := NewColor(1.0, 1.0, 1.0).Scale(1.0 - t)
-		// c2 := NewColor(0.5, 0.7, 1.0).Scale(t)
-		// return c1.Plus(c2)
-		return NewColor(0, 0, 0)
-	}
+// Intersect checks a ray against a Hitable (usually one of the scene
+// BVHs), returning if there was a hit and the record of the nearest one
+func (scene Scene) intersect(r geom.Ray, world Hitable) (hit bool, rec HitRecord) {
+	rec, hit = world.Hit(r, bias, math.MaxFloat64)
+	return
+}
 
+// directLight estimates the light arriving at a hit point straight
+// from the scene lights, sampling a point on each of them. With mis,
+// samples are weighted against the BSDF sampling of the material.
+func (scene Scene) directLight(rec *HitRecord, wo geom.Vec3, mis bool, rnd *rand.Rand) Color {
 	result := NewColor(0.0, 0.0, 0.0)
-	incident := r.Dir.Unit()
-	p := r.At(tNear)
-	n, m := surf.Surface(p)
-	orientedN := n
-	if n.Dot(incident) >= 0.0 {
-		orientedN = orientedN.Inv()
-	}
-
-	/* Debugging: Global map
-	irrad := scene.globalPmap.IrradianceEst(p, n, 0, 50)
-	result = result.Plus(NewColor(irrad.X(), irrad.Y(), irrad.Z()))
-	return result
-	*/
-
-	/* Debugging: Caustics map
-	irrad := scene.causticPmap.IrradianceEst(p, n, 0, 50)
-	result = result.Plus(NewColor(irrad.X(), irrad.Y(), irrad.Z()))
-	return result
-	*/
-
-	// "Normal" material
-	if m.Normal {
-		return NewColor(n.X()+0.5, n.Y()+0.5, n.Z()+0.5).Scale(0.5)
-	}
-
-	if m.Emittance > 0 {
-		result = m.Color.Scale(m.Emittance)
-	} else if m.Lambert { // Lambertian material
-		scattered := geom.SampleHemisphereNormal(n, rnd)
-		if scattered.NearZero() {
-			scattered = n
+	for _, l := range scene.Lights {
+		sampler, ok := l.(LightSampler)
+		if !ok {
+			continue
 		}
-		r2 := geom.NewRay(p, scattered)
-		result = result.Plus(scene.trace(r2, depth+1, rnd).Times(m.Color))
-	} else if m.Reflectivity > 0 { // Metalic material
-		reflected := incident.Reflect(n)
-		// Add roughness/fuzzyness
-		reflected = reflected.Plus(geom.SampleHemisphereNormal(n, rnd).Scale(m.Roughness))
-		if reflected.Dot(n) > 0 {
-			r2 := geom.NewRay(p, reflected)
-			result = result.Plus(scene.trace(r2, depth+1, rnd).Times(m.Color).Scale(m.Reflectivity))
+		ls, ok := sampler.SampleLight(rec.P, rnd)
+		if !ok {
+			continue
 		}
-	} else if m.Transparent { // Dielectric material
-		etai, etat := 1.0, m.RefrIndex
-		refrRatio := etai / etat
-
-		refracts, rayDir := incident.Refract(n, refrRatio)
-		if !refracts {
-			rayDir = incident.Reflect(n)
+		wi := ls.P.Minus(rec.P).Unit()
+		f := rec.Mat.Eval(rec, wo, wi)
+		if f.Black() || !scene.visible(rec.P, ls.P) {
+			continue
 		}
-		r2 := geom.NewRay(p, rayDir)
-		result = result.Plus(scene.trace(r2, depth+1, rnd))
-	} else {
-		// Material is diffuse
-
-		/* Photon mapping
-		const BRDF float64 = 1 / math.Pi
-		irrad := geom.NewVec3(0.0, 0.0, 0.0)
-
-		// Caustics
-		irrad = irrad.Plus(scene.causticPmap.IrradianceEst(p, n, 1, 100).Scale(BRDF))
-
-		// Global illumination
-		irrad = irrad.Plus(scene.globalPmap.IrradianceEst(p, n, 0, 100).Scale(BRDF))
-
-		irradColor := Color{Vec3: irrad}
-		result = result.Plus(irradColor.Times(m.Color))
-		*/
-
-		/* Direct illumination */
-		for _, l := range scene.Lights {
-			pos := l.Pos()
-			dir := pos.Minus(p).Unit()
-			power := l.Material().Color
-			fd := n.Dot(dir)
-			if fd < 0 {
-				fd = 0
-			}
-			// calculate shadow
-			visible := 1.0
-			tMin, tMax := bias, math.MaxFloat64
-			tNear := tMax
-			shadowRay := geom.NewRay(p, dir)
-			for _, o := range scene.Objects {
-				if ht, _ := o.Hit(shadowRay, tMin, tNear); ht > 0.0 {
-					m := o.Material()
-					if m.Emittance == 0 {
-						visible = 0.0
-					}
-					tNear = ht
-				}
-			}
-			result = result.Plus(m.Color.Scale(fd).Times(power).Scale(visible))
+		lrec := HitRecord{P: ls.P, Ng: ls.N, N: ls.N, FrontFace: true, Mat: l.Material()}
+		le := lrec.Mat.Emitted(&lrec, wi.Inv())
+		w := 1.0
+		if mis {
+			w = powerHeuristic(ls.PDF, rec.Mat.PDF(rec, wo, wi))
 		}
+		result = result.Plus(f.Times(le).Scale(w / ls.PDF))
 	}
 	return result
 }
 
-// tracePhotons traces photons emitted from a light source,
-// storing them if the surface hit is diffuse, and bouncing
-// them otherwise.
+// visible tells if nothing blocks the segment between p and q
+func (scene Scene) visible(p, q geom.Vec3) bool {
+	d := q.Minus(p)
+	dist := d.Len()
+	_, hit := scene.world.Hit(geom.NewRay(p, d.Scale(1.0/dist)), bias, dist-bias)
+	return !hit
+}
+
+// tracePhotons traces a photon emitted from a light source, storing
+// it at every diffuse surface it reaches and bouncing it on by russian
+// roulette. Caustic photons must reach a diffuse surface through
+// specular bounces only, and are stored there once.
 func (scene Scene) tracePhotons(r geom.Ray, depth int, power Color, pmap *PhotonMap, caustics bool, rnd *rand.Rand) {
-	if depth >= scene.maxDepth {
+	if depth >= scene.MaxDepth {
 		return
 	}
 
 	if caustics && depth == 1 {
-		if hit, _, _ := scene.intersect(r, scene.tObjects); !hit {
+		if hit, _ := scene.intersect(r, scene.tWorld); !hit {
 			return
 		}
 	}
 
-	hit, tNear, surf := scene.intersect(r, scene.Objects)
+	hit, rec := scene.intersect(r, scene.world)
 
 	if !hit {
 		return
 	}
 
 	incident := r.Dir.Unit()
-	p := r.At(tNear)
-	n, m := surf.Surface(p)
+	p, n := rec.P, rec.N
+	s, ok := rec.Mat.Sample(&rec, incident.Inv(), rnd)
 
-	if caustics && depth == 1 && !m.Transparent {
+	if ok && s.Specular {
+		r2 := geom.NewRay(p, s.Wi)
+		scene.tracePhotons(r2, depth+1, power.Times(s.Weight).Times(absorption(r, &rec)), pmap, caustics, rnd)
 		return
 	}
-
-	// Properly oriented normal
-	orientedN := n
-	if n.Dot(incident) >= 0.0 {
-		orientedN = orientedN.Inv()
+	if caustics && depth == 1 {
+		return
 	}
-	// BRDF modulator
-	f := m.Color
-	// Maximum reflectivity for russian roulette
-	// rrp := math.Max(math.Max(f.R(), f.G()), f.B())
-	rrp := (f.R() + f.G() + f.B()) / 3
 
-	// "Normal" material
-	if m.Normal || m.Emittance > 0 {
+	if rec.Mat.Eval(&rec, incident.Inv(), n).Black() { // lights and debug materials absorb photons
+		return
+	}
+	pmap.Store(power.E, p.E, incident.E)
+	if caustics {
 		return
 	}
 
-	if m.Lambert { // Lambertian material
-
-	} else if m.Reflectivity > 0 { // Metalic material
-		reflected := incident.Reflect(n)
-		// Add roughness/fuzzyness
-		reflected = reflected.Plus(geom.SampleHemisphereNormal(orientedN, rnd).Scale(m.Roughness))
-		r2 := geom.NewRay(p, reflected)
-		scene.tracePhotons(r2, depth+1, f.Times(power), pmap, caustics, rnd)
-	} else if m.Transparent { // Dielectric material
-		etai, etat := 1.0, m.RefrIndex
-		refrRatio := etai / etat
-
-		refracts, rayDir := incident.Refract(n, refrRatio)
-		if !refracts {
-			rayDir = incident.Reflect(n)
-		}
-		r2 := geom.NewRay(p, rayDir)
+	if r2, power, ok := reflectPhoton(&rec, incident.Inv(), s, ok, power, rnd); ok {
 		scene.tracePhotons(r2, depth+1, power, pmap, caustics, rnd)
+	}
+}
+
+// reflectPhoton continues a photon arriving from wi at a diffuse
+// surface along the direction s sampled from its material, returning
+// false when russian roulette absorbs the photon
+func reflectPhoton(rec *HitRecord, wi geom.Vec3, s BSDFSample, ok bool, power Color, rnd *rand.Rand) (geom.Ray, Color, bool) {
+	// BRDF modulator, the reflectance of the surface
+	f := rec.Mat.Eval(rec, wi, rec.N).Scale(math.Pi)
+
+	// Average reflectivity for russian roulette
+	rrp := (f.R() + f.G() + f.B()) / 3
+	if rrp <= 0 || rnd.Float64() >= rrp { // absorb photon
+		return geom.Ray{}, power, false
+	}
+	if ok {
+		power = power.Times(s.Weight)
 	} else {
-		if rnd.Float64() < rrp { // absorb photon
-			// fmt.Println("absorb photon", depth)
-			att := f.Times(power).Scale(1.0 / (1.0 - rrp))
-			pmap.Store(att.E, p.E, incident.E)
-		} else { // trace another ray
-			// Random ray
-			r2 := geom.NewRay(p, geom.SampleHemisphereNormal(n, rnd))
-			scene.tracePhotons(r2, depth+1, f.Times(power).Scale(1.0/rrp), pmap, caustics, rnd)
-		}
+		// Materials lit only directly still reflect photons
+		s.Wi = geom.SampleHemisphereNormal(rec.N, rnd)
+		power = power.Times(f)
+	}
+	return geom.NewRay(rec.P, s.Wi), power.Scale(1.0 / rrp), true
+}
+
+// absorption returns the attenuation of a ray that hit the inside of
+// an absorbing material, having travelled through its interior
+func absorption(r geom.Ray, rec *HitRecord) Color {
+	if a, ok := rec.Mat.(absorber); ok && !rec.FrontFace {
+		return a.transmittance(rec.P.Minus(r.Orig).Len())
 	}
+	return NewColor(1, 1, 1)
 }
@@ -2,6 +2,7 @@ package tracer
 
 import (
 	"math"
+	"math/rand"
 
 	"github.com/gabrielfvale/go-raytracer/pkg/geom"
 )
@@ -19,8 +20,8 @@ func NewSphere(center geom.Vec3, radius float64, mat Material) Sphere {
 }
 
 // Hit checks if a Ray hit the sphere, returning
-// t, p (point in ray) and n (surface normal)
-func (s Sphere) Hit(r geom.Ray, tMin, tMax float64) (t float64, surf Surface) {
+// the hit record of the nearest root in [tMin, tMax]
+func (s Sphere) Hit(r geom.Ray, tMin, tMax float64) (rec HitRecord, hit bool) {
 	oc := r.Orig.Minus(s.Center)
 	a := r.Dir.LenSq()
 	halfB := oc.Dot(r.Dir)
@@ -28,21 +29,97 @@ func (s Sphere) Hit(r geom.Ray, tMin, tMax float64) (t float64, surf Surface) {
 	disc := halfB*halfB - a*c
 
 	if disc < 0.0 {
-		return -1.0, s
+		return
 	}
 
 	sqrtd := math.Sqrt(disc)
 	// Test both roots
-	t = (-halfB - sqrtd) / a
-	if t > tMin && t < tMax {
-		return t, s
+	t := (-halfB - sqrtd) / a
+	if t <= tMin || t >= tMax {
+		t = (-halfB + sqrtd) / a
+		if t <= tMin || t >= tMax {
+			return
+		}
 	}
-	t = (-halfB + sqrtd) / a
-	if t > tMin && t < tMax {
-		return t, s
+
+	rec.T = t
+	rec.P = r.At(t)
+	d := rec.P.Minus(s.Center).Scale(1.0 / s.Radius)
+	rec.SetFaceNormal(r, d, d)
+
+	// Spherical coordinates, with v = 1 at the top of the sphere
+	phi := math.Atan2(d.Z(), d.X())
+	theta := math.Acos(math.Max(-1.0, math.Min(1.0, d.Y())))
+	rec.U = (phi + math.Pi) / (2.0 * math.Pi)
+	rec.V = 1.0 - theta/math.Pi
+
+	sinTheta := math.Sin(theta)
+	if sinTheta > 1e-8 {
+		rec.DPdu = geom.NewVec3(-d.Z(), 0, d.X()).Scale(2.0 * math.Pi * s.Radius)
+		rec.DPdv = geom.NewVec3(d.Y()*d.X()/sinTheta, -sinTheta, d.Y()*d.Z()/sinTheta).Scale(-math.Pi * s.Radius)
+	} else {
+		// The parametrization is degenerate at the poles
+		rec.DPdu, rec.DPdv = tangents(d)
+	}
+	rec.Mat = s.Mat
+	return rec, true
+}
+
+// cone returns the cosine of the half angle and the solid angle
+// of the cone that the sphere subtends from p, outside the sphere
+func (s Sphere) cone(p geom.Vec3) (cosMax, solidAngle float64, ok bool) {
+	dist2 := s.Center.Minus(p).LenSq()
+	r2 := s.Radius * s.Radius
+	if dist2 <= r2 {
+		return
+	}
+	sin2Max := r2 / dist2
+	cosMax = math.Sqrt(1 - sin2Max)
+	// 2 pi (1 - cosMax), without cancellation for small spheres
+	solidAngle = 2 * math.Pi * sin2Max / (1 + cosMax)
+	return cosMax, solidAngle, true
+}
+
+// SampleLight chooses a direction uniformly in the cone that the
+// sphere subtends from p, returning the nearest point along it
+func (s Sphere) SampleLight(p geom.Vec3, rnd *rand.Rand) (ls LightSample, ok bool) {
+	cosMax, solidAngle, ok := s.cone(p)
+	if !ok { // p is inside the sphere
+		return
 	}
+	wc := s.Center.Minus(p)
+	dist2 := wc.LenSq()
+	dist := math.Sqrt(dist2)
+
+	cos := 1 - rnd.Float64()*(1-cosMax)
+	sin := math.Sqrt(math.Max(0, 1-cos*cos))
+	phi := 2 * math.Pi * rnd.Float64()
+	w := wc.Scale(1 / dist)
+	u, v := tangents(w)
+	dir := u.Scale(sin * math.Cos(phi)).Plus(v.Scale(sin * math.Sin(phi))).Plus(w.Scale(cos))
+
+	t := dist*cos - math.Sqrt(math.Max(0, s.Radius*s.Radius-dist2*sin*sin))
+	ls.P = p.Plus(dir.Scale(t))
+	ls.N = ls.P.Minus(s.Center).Scale(1 / s.Radius)
+	ls.PDF = 1 / solidAngle
+	return ls, true
+}
+
+func (s Sphere) LightPDF(p geom.Vec3, rec *HitRecord) float64 {
+	if _, solidAngle, ok := s.cone(p); ok {
+		return 1 / solidAngle
+	}
+	return 0
+}
+
+// emitPoint chooses a point uniformly on the surface of the sphere
+func (s Sphere) emitPoint(rnd *rand.Rand) (p, n geom.Vec3) {
+	n = geom.SampleSphere(rnd)
+	return s.Center.Plus(n.Scale(s.Radius)), n
+}
 
-	return -1.0, s
+func (s Sphere) emitArea() float64 {
+	return 4 * math.Pi * s.Radius * s.Radius
 }
 
 func (s Sphere) Material() (m Material) {
@@ -53,6 +130,7 @@ func (s Sphere) Pos() (p geom.Vec3) {
 	return s.Center
 }
 
-func (s Sphere) Surface(p geom.Vec3) (n geom.Vec3, m Material) {
-	return p.Minus(s.Center).Scale(s.Radius).Unit(), s.Mat
+func (s Sphere) Bounds() Bounds {
+	r := geom.NewVec3(s.Radius, s.Radius, s.Radius)
+	return Bounds{Min: s.Center.Minus(r), Max: s.Center.Plus(r)}
 }
@@ -0,0 +1,305 @@
+package tracer
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+	"github.com/gabrielfvale/go-raytracer/pkg/util"
+)
+
+// SPPMIntegrator renders with stochastic progressive photon mapping.
+// Every iteration an eye pass finds the first diffuse surface seen
+// through each pixel, and a photon pass gathers the photons landing
+// near those surfaces, which are then dropped. The gather radius of
+// each pixel shrinks as photons arrive, so the estimate converges
+// with more iterations without keeping a photon map in memory.
+// Each pass traces Photons photons, or one per pixel when 0, and
+// gathers within Radius at first, or a hundredth of the scene size
+// when 0.
+type SPPMIntegrator struct {
+	Photons int
+	Radius  float64
+}
+
+// progressive is implemented by integrators that refine the
+// whole image at once, taking one sample per pixel per iteration,
+// and return the pixels of the scene window row by row
+type progressive interface {
+	render(scene *Scene, iterations int) []Color
+}
+
+// sppmAlpha is the fraction of the photons of an iteration
+// kept when the gather radius shrinks
+const sppmAlpha = 2.0 / 3.0
+
+// visiblePoint holds the SPPM statistics of a pixel
+type visiblePoint struct {
+	mu   sync.Mutex
+	rec  HitRecord
+	wo   geom.Vec3
+	beta Color // throughput of the eye path, black without a surface
+	ld   Color // sum of the light seen directly
+
+	radius float64
+	n      float64 // photons kept so far
+	tau    Color   // flux of the photons kept so far
+	phi    Color   // flux of the photons of this iteration
+	m      int     // photons of this iteration
+}
+
+func (SPPMIntegrator) Preprocess(scene *Scene) {}
+
+// Li returns the direct lighting only, as the full estimate
+// needs the statistics of every pixel
+func (SPPMIntegrator) Li(scene *Scene, r geom.Ray, rnd *rand.Rand) Color {
+	return DirectIntegrator{}.Li(scene, r, rnd)
+}
+
+func (s SPPMIntegrator) render(scene *Scene, iterations int) []Color {
+	photons := s.Photons
+	if photons <= 0 {
+		photons = scene.W * scene.H
+	}
+	radius := s.Radius
+	if radius <= 0 {
+		b := scene.Bounds()
+		radius = b.Max.Minus(b.Min).Len() / 100
+	}
+	win := scene.Window()
+	pixels := make([]visiblePoint, win.Dx()*win.Dy())
+	for i := range pixels {
+		pixels[i].radius = radius
+	}
+
+	lights := scene.lightDistribution()
+	log.Printf("Rendering scene (%d photons per iteration)", photons)
+	bar := util.NewProgress(0, iterations)
+	seed := time.Now().UnixNano()
+	for it := 0; it < iterations; it++ {
+		parallel(win.Dy(), seed, func(y int, rnd *rand.Rand) {
+			for x := 0; x < win.Dx(); x++ {
+				s.eyePass(scene, &pixels[y*win.Dx()+x], win.Min.X+x, win.Min.Y+y, rnd)
+			}
+		})
+		seed += int64(runtime.NumCPU() + 1)
+
+		grid := newPointGrid(pixels)
+		parallel(photons, seed, func(i int, rnd *rand.Rand) {
+			s.photonPass(scene, grid, lights, rnd)
+		})
+		seed += int64(runtime.NumCPU() + 1)
+
+		for i := range pixels {
+			pixels[i].update()
+		}
+		bar.Tick()
+	}
+
+	img := make([]Color, len(pixels))
+	emitted := float64(iterations) * float64(photons)
+	for i := range pixels {
+		vp := &pixels[i]
+		direct := vp.ld.Scale(1.0 / float64(iterations))
+		indirect := vp.tau.Scale(1.0 / (emitted * math.Pi * vp.radius * vp.radius))
+		img[i] = direct.Plus(indirect)
+	}
+	return img
+}
+
+// eyePass follows a camera ray through pixel x, y up to the first
+// diffuse surface, adding the light seen directly to the pixel and
+// recording the surface to gather photons from
+func (SPPMIntegrator) eyePass(scene *Scene, vp *visiblePoint, x, y int, rnd *rand.Rand) {
+	vp.beta = Color{}
+	u := (float64(x) + rnd.Float64()) / float64(scene.W)
+	v := (float64(y) + rnd.Float64()) / float64(scene.H)
+	r, ok := scene.Cam.Ray(u, v, rnd)
+	if !ok {
+		return
+	}
+	throughput := NewColor(1.0, 1.0, 1.0)
+	for depth := 1; depth < scene.MaxDepth; depth++ {
+		hit, rec := scene.intersect(r, scene.world)
+		if !hit {
+			return
+		}
+		wo := r.Dir.Unit().Inv()
+		throughput = throughput.Times(absorption(r, &rec))
+		vp.ld = vp.ld.Plus(throughput.Times(rec.Mat.Emitted(&rec, wo)))
+
+		if s, ok := rec.Mat.Sample(&rec, wo, rnd); ok && s.Specular {
+			throughput = throughput.Times(s.Weight)
+			r = geom.NewRay(rec.P, s.Wi)
+			continue
+		}
+		vp.ld = vp.ld.Plus(throughput.Times(scene.directLight(&rec, wo, false, rnd)))
+		vp.rec, vp.wo, vp.beta = rec, wo, throughput
+		return
+	}
+}
+
+// photonPass traces a photon from a light chosen by its power in
+// lights, the cumulative power of the scene lights, adding it to the
+// visible points around every diffuse surface it reaches after the
+// first, whose light the eye pass samples directly
+func (SPPMIntegrator) photonPass(scene *Scene, grid *pointGrid, lights []float64, rnd *rand.Rand) {
+	if len(lights) == 0 || lights[len(lights)-1] <= 0 {
+		return
+	}
+	total := lights[len(lights)-1]
+	i := sort.SearchFloat64s(lights, rnd.Float64()*total)
+	if i == len(lights) {
+		i--
+	}
+	pdf := lights[i]
+	if i > 0 {
+		pdf -= lights[i-1]
+	}
+	l := scene.Lights[i]
+	r, ok := emitPhoton(l, rnd)
+	if !ok {
+		return
+	}
+	power := lightPower(l).Scale(total / pdf)
+	for depth := 1; depth < scene.MaxDepth; depth++ {
+		hit, rec := scene.intersect(r, scene.world)
+		if !hit {
+			return
+		}
+		wi := r.Dir.Unit().Inv()
+		power = power.Times(absorption(r, &rec))
+
+		s, ok := rec.Mat.Sample(&rec, wi, rnd)
+		if ok && s.Specular {
+			power = power.Times(s.Weight)
+			r = geom.NewRay(rec.P, s.Wi)
+			continue
+		}
+		if depth > 1 {
+			grid.add(rec.P, wi, power)
+		}
+		if r, power, ok = reflectPhoton(&rec, wi, s, ok, power, rnd); !ok {
+			return
+		}
+	}
+}
+
+// update shrinks the gather radius of the pixel,
+// keeping part of the photons of the iteration
+func (vp *visiblePoint) update() {
+	if vp.m > 0 {
+		n := vp.n + sppmAlpha*float64(vp.m)
+		radius := vp.radius * math.Sqrt(n/(vp.n+float64(vp.m)))
+		tau := vp.tau.Plus(vp.beta.Times(vp.phi))
+		vp.tau = tau.Scale((radius * radius) / (vp.radius * vp.radius))
+		vp.n, vp.radius = n, radius
+	}
+	vp.phi, vp.m = Color{}, 0
+}
+
+// pointGrid is a hashed uniform grid of the visible points,
+// each stored in every cell its gather radius overlaps
+type pointGrid struct {
+	pixels []visiblePoint
+	cells  [][]int32
+	size   float64
+}
+
+// newPointGrid returns a grid of the pixels that found a surface,
+// with cells as large as the largest gather radius
+func newPointGrid(pixels []visiblePoint) *pointGrid {
+	g := &pointGrid{pixels: pixels, cells: make([][]int32, len(pixels))}
+	for i := range pixels {
+		if !pixels[i].beta.Black() {
+			g.size = math.Max(g.size, pixels[i].radius)
+		}
+	}
+	if g.size == 0 {
+		return g
+	}
+	for i := range pixels {
+		vp := &pixels[i]
+		if vp.beta.Black() {
+			continue
+		}
+		r := geom.NewVec3(vp.radius, vp.radius, vp.radius)
+		lo, hi := g.cell(vp.rec.P.Minus(r)), g.cell(vp.rec.P.Plus(r))
+		for x := lo[0]; x <= hi[0]; x++ {
+			for y := lo[1]; y <= hi[1]; y++ {
+				for z := lo[2]; z <= hi[2]; z++ {
+					h := g.hash([3]int{x, y, z})
+					g.cells[h] = append(g.cells[h], int32(i))
+				}
+			}
+		}
+	}
+	return g
+}
+
+// cell returns the grid coordinates of point p
+func (g *pointGrid) cell(p geom.Vec3) (c [3]int) {
+	for d := 0; d < 3; d++ {
+		c[d] = int(math.Floor(p.E[d] / g.size))
+	}
+	return
+}
+
+func (g *pointGrid) hash(c [3]int) int {
+	h := uint(c[0]*73856093) ^ uint(c[1]*19349663) ^ uint(c[2]*83492791)
+	return int(h % uint(len(g.cells)))
+}
+
+// add splats a photon of the given power arriving at p
+// from wi onto the visible points around p
+func (g *pointGrid) add(p, wi geom.Vec3, power Color) {
+	if g.size == 0 {
+		return
+	}
+	for _, i := range g.cells[g.hash(g.cell(p))] {
+		vp := &g.pixels[i]
+		if p.Minus(vp.rec.P).LenSq() > vp.radius*vp.radius {
+			continue
+		}
+		// The photon flux already accounts for the cosine
+		cos := wi.Dot(vp.rec.N)
+		if cos <= 0.0 {
+			continue
+		}
+		f := vp.rec.Mat.Eval(&vp.rec, vp.wo, wi).Scale(1.0 / cos)
+		vp.mu.Lock()
+		vp.phi = vp.phi.Plus(f.Times(power))
+		vp.m++
+		vp.mu.Unlock()
+	}
+}
+
+// parallel calls job for every index up to n over a worker per CPU,
+// each worker with its own random stream seeded from seed
+func parallel(n int, seed int64, job func(i int, rnd *rand.Rand)) {
+	const chunk = 64
+	workers := runtime.NumCPU() + 1
+	jobs := make(chan int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(rnd *rand.Rand) {
+			defer wg.Done()
+			for start := range jobs {
+				for i := start; i < start+chunk && i < n; i++ {
+					job(i, rnd)
+				}
+			}
+		}(rand.New(rand.NewSource(seed + int64(w))))
+	}
+	for start := 0; start < n; start += chunk {
+		jobs <- start
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,54 @@
+package tracer
+
+import (
+	"image"
+	"math"
+)
+
+// Texture represents a color that varies over a surface
+type Texture interface {
+	Value(u, v float64) Color
+}
+
+// Type definition for ImageTexture
+type ImageTexture struct {
+	W, H   int
+	pixels []Color
+}
+
+// NewImageTexture returns an ImageTexture sampling img. Pixel values
+// are decoded from sRGB to linear space, inverting the output curve.
+func NewImageTexture(img image.Image) *ImageTexture {
+	b := img.Bounds()
+	t := &ImageTexture{W: b.Dx(), H: b.Dy(), pixels: make([]Color, b.Dx()*b.Dy())}
+	for y := 0; y < t.H; y++ {
+		for x := 0; x < t.W; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			t.pixels[y*t.W+x] = NewColor(
+				srgbLinear(float64(r)/0xffff),
+				srgbLinear(float64(g)/0xffff),
+				srgbLinear(float64(bl)/0xffff),
+			)
+		}
+	}
+	return t
+}
+
+// Value returns the texel at u, v, repeating the image outside [0, 1].
+// The v axis points up, as in Wavefront OBJ files.
+func (t *ImageTexture) Value(u, v float64) Color {
+	if t.W == 0 || t.H == 0 {
+		return NewColor(0, 0, 0)
+	}
+	u = u - math.Floor(u)
+	v = 1.0 - (v - math.Floor(v))
+	x := int(u * float64(t.W))
+	y := int(v * float64(t.H))
+	if x >= t.W {
+		x = t.W - 1
+	}
+	if y >= t.H {
+		y = t.H - 1
+	}
+	return t.pixels[y*t.W+x]
+}
@@ -0,0 +1,124 @@
+package tracer
+
+import "math"
+
+// ToneOperator is a curve compressing scene radiance to display range
+type ToneOperator int
+
+const (
+	// LinearTone clips the radiance above white
+	LinearTone ToneOperator = iota
+	// ReinhardTone maps luminance L to L/(1+L)
+	ReinhardTone
+	// ExtendedReinhardTone is Reinhard with the White luminance
+	// mapped to white, burning out what is brighter
+	ExtendedReinhardTone
+	// HableTone is the filmic curve of Uncharted 2 by John Hable,
+	// with White as the linear white point
+	HableTone
+	// ACESTone is the ACES filmic reference and output transforms,
+	// as fitted by Stephen Hill
+	ACESTone
+)
+
+// Default white points of the operators that take one
+const (
+	reinhardWhite = 4.0
+	hableWhite    = 11.2
+)
+
+// ToneMapping converts linear radiance to sRGB display colors,
+// scaling it by the Exposure, in stops, before the Operator.
+// White is the white point of ExtendedReinhardTone and HableTone,
+// or 0 for their defaults.
+type ToneMapping struct {
+	Operator ToneOperator
+	Exposure float64
+	White    float64
+}
+
+// Map returns the sRGB encoded display color of radiance c
+func (t ToneMapping) Map(c Color) Color {
+	c = c.Scale(math.Exp2(t.Exposure))
+	switch t.Operator {
+	case ReinhardTone:
+		c = reinhard(c, math.Inf(1))
+	case ExtendedReinhardTone:
+		c = reinhard(c, t.white(reinhardWhite))
+	case HableTone:
+		w := hable(t.white(hableWhite))
+		c = NewColor(hable(2*c.R())/w, hable(2*c.G())/w, hable(2*c.B())/w)
+	case ACESTone:
+		c = aces(c)
+	}
+	return NewColor(srgb(c.R()), srgb(c.G()), srgb(c.B()))
+}
+
+func (t ToneMapping) white(def float64) float64 {
+	if t.White > 0 {
+		return t.White
+	}
+	return def
+}
+
+// Luminance returns the relative luminance of linear sRGB color c
+func (c Color) Luminance() float64 {
+	return 0.2126*c.R() + 0.7152*c.G() + 0.0722*c.B()
+}
+
+// reinhard compresses the luminance of c, keeping its hue,
+// so that white maps to 1
+func reinhard(c Color, white float64) Color {
+	l := c.Luminance()
+	if l <= 0 {
+		return NewColor(0.0, 0.0, 0.0)
+	}
+	return c.Scale((1 + l/(white*white)) / (1 + l))
+}
+
+// hable is the filmic curve of John Hable
+func hable(x float64) float64 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return (x*(a*x+c*b)+d*e)/(x*(a*x+b)+d*f) - e/f
+}
+
+// aces applies the fit of Stephen Hill to the ACES reference
+// rendering and sRGB output transforms, from and to linear sRGB
+func aces(c Color) Color {
+	in := [3][3]float64{
+		{0.59719, 0.35458, 0.04823},
+		{0.07600, 0.90834, 0.01566},
+		{0.02840, 0.13383, 0.83777},
+	}
+	out := [3][3]float64{
+		{1.60475, -0.53108, -0.07367},
+		{-0.10208, 1.10813, -0.00605},
+		{-0.00327, -0.07276, 1.07602},
+	}
+	var v [3]float64
+	for i := range v {
+		x := in[i][0]*c.R() + in[i][1]*c.G() + in[i][2]*c.B()
+		v[i] = (x*(x+0.0245786) - 0.000090537) / (x*(0.983729*x+0.4329510) + 0.238081)
+	}
+	var r [3]float64
+	for i := range r {
+		r[i] = out[i][0]*v[0] + out[i][1]*v[1] + out[i][2]*v[2]
+	}
+	return NewColor(r[0], r[1], r[2])
+}
+
+// srgb applies the sRGB transfer function to a linear value
+func srgb(x float64) float64 {
+	if x <= 0.0031308 {
+		return 12.92 * x
+	}
+	return 1.055*math.Pow(x, 1/2.4) - 0.055
+}
+
+// srgbLinear inverts srgb, returning the linear value of x
+func srgbLinear(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
@@ -0,0 +1,234 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/gabrielfvale/go-raytracer/pkg/geom"
+)
+
+// Type definition for Triangle
+type Triangle struct {
+	V          [3]geom.Vec3
+	N          [3]geom.Vec3
+	UV         [3][2]float64
+	HasNormals bool
+	HasUVs     bool
+	Mat        Material
+}
+
+// NewTriangle returns a flat Triangle given its vertices,
+// in counter-clockwise order
+func NewTriangle(v0, v1, v2 geom.Vec3, mat Material) Triangle {
+	return Triangle{V: [3]geom.Vec3{v0, v1, v2}, Mat: mat}
+}
+
+// NewSmoothTriangle returns a Triangle with per-vertex normals and UVs.
+// Passing nil normals or uvs keeps the triangle flat or unmapped.
+func NewSmoothTriangle(v, n *[3]geom.Vec3, uv *[3][2]float64, mat Material) Triangle {
+	t := Triangle{V: *v, Mat: mat}
+	if n != nil {
+		t.N = *n
+		t.HasNormals = true
+	}
+	if uv != nil {
+		t.UV = *uv
+		t.HasUVs = true
+	}
+	return t
+}
+
+// maxDim returns the index of the largest component of v
+func maxDim(v geom.Vec3) int {
+	if v.X() > v.Y() {
+		if v.X() > v.Z() {
+			return 0
+		}
+		return 2
+	}
+	if v.Y() > v.Z() {
+		return 1
+	}
+	return 2
+}
+
+// Hit checks if a Ray hit the triangle using the watertight
+// algorithm by Woop, Benthin and Wald, so rays never slip
+// through the shared edges of adjacent triangles
+func (tri Triangle) Hit(r geom.Ray, tMin, tMax float64) (rec HitRecord, hit bool) {
+	// Permute axes so that the ray travels along +z
+	dir := r.Dir
+	kz := maxDim(geom.NewVec3(math.Abs(dir.X()), math.Abs(dir.Y()), math.Abs(dir.Z())))
+	kx := (kz + 1) % 3
+	ky := (kx + 1) % 3
+	if dir.E[kz] < 0.0 {
+		kx, ky = ky, kx
+	}
+
+	// Shear constants
+	sx := dir.E[kx] / dir.E[kz]
+	sy := dir.E[ky] / dir.E[kz]
+	sz := 1.0 / dir.E[kz]
+
+	a := tri.V[0].Minus(r.Orig)
+	b := tri.V[1].Minus(r.Orig)
+	c := tri.V[2].Minus(r.Orig)
+
+	ax, ay := a.E[kx]-sx*a.E[kz], a.E[ky]-sy*a.E[kz]
+	bx, by := b.E[kx]-sx*b.E[kz], b.E[ky]-sy*b.E[kz]
+	cx, cy := c.E[kx]-sx*c.E[kz], c.E[ky]-sy*c.E[kz]
+
+	// Scaled barycentric coordinates
+	u := cx*by - cy*bx
+	v := ax*cy - ay*cx
+	w := bx*ay - by*ax
+
+	if (u < 0.0 || v < 0.0 || w < 0.0) && (u > 0.0 || v > 0.0 || w > 0.0) {
+		return
+	}
+
+	det := u + v + w
+	if det == 0.0 {
+		return
+	}
+
+	az, bz, cz := sz*a.E[kz], sz*b.E[kz], sz*c.E[kz]
+	t := (u*az + v*bz + w*cz) / det
+	if t <= tMin || t >= tMax {
+		return
+	}
+
+	inv := 1.0 / det
+	bary := [3]float64{u * inv, v * inv, w * inv}
+	rec.T = t
+	rec.P = tri.Interpolate(bary)
+	rec.U, rec.V = tri.TexCoord(bary)
+	rec.DPdu, rec.DPdv = tri.tangents()
+
+	// Keep the geometric normal in the hemisphere of the shading
+	// normal, so that meshes with flipped winding still shade correctly
+	ng, n := tri.Normal(), tri.ShadingNormal(bary)
+	if ng.Dot(n) < 0.0 {
+		ng = ng.Inv()
+	}
+	rec.SetFaceNormal(r, ng, n)
+
+	rec.Mat = tri.Mat
+	return rec, true
+}
+
+// tangents returns the partial derivatives of the position with
+// respect to the UV coordinates, or an arbitrary tangent frame
+// when the triangle has no (or degenerate) UVs
+func (tri Triangle) tangents() (dpdu, dpdv geom.Vec3) {
+	if tri.HasUVs {
+		du02, dv02 := tri.UV[0][0]-tri.UV[2][0], tri.UV[0][1]-tri.UV[2][1]
+		du12, dv12 := tri.UV[1][0]-tri.UV[2][0], tri.UV[1][1]-tri.UV[2][1]
+		det := du02*dv12 - dv02*du12
+		if math.Abs(det) > 1e-12 {
+			dp02 := tri.V[0].Minus(tri.V[2])
+			dp12 := tri.V[1].Minus(tri.V[2])
+			inv := 1.0 / det
+			dpdu = dp02.Scale(dv12).Minus(dp12.Scale(dv02)).Scale(inv)
+			dpdv = dp12.Scale(du02).Minus(dp02.Scale(du12)).Scale(inv)
+			return
+		}
+	}
+	return tangents(tri.Normal())
+}
+
+// SampleLight chooses a point uniformly on the triangle,
+// which emits light from both of its sides
+func (tri Triangle) SampleLight(p geom.Vec3, rnd *rand.Rand) (s LightSample, ok bool) {
+	s.P, s.N = tri.samplePoint(rnd)
+	if s.N.Dot(p.Minus(s.P)) < 0 {
+		s.N = s.N.Inv()
+	}
+	return s, s.fromArea(p, 1/tri.Area())
+}
+
+func (tri Triangle) LightPDF(p geom.Vec3, rec *HitRecord) float64 {
+	return solidAnglePDF(p, rec.P, rec.Ng, 1/tri.Area())
+}
+
+// samplePoint returns a uniformly distributed point on the
+// triangle and its geometric normal
+func (tri Triangle) samplePoint(rnd *rand.Rand) (p, n geom.Vec3) {
+	su := math.Sqrt(rnd.Float64())
+	b1 := 1 - su
+	b2 := rnd.Float64() * su
+	return tri.Interpolate([3]float64{1 - b1 - b2, b1, b2}), tri.Normal()
+}
+
+// emitPoint chooses a point uniformly on either side of the triangle
+func (tri Triangle) emitPoint(rnd *rand.Rand) (p, n geom.Vec3) {
+	p, n = tri.samplePoint(rnd)
+	if rnd.Float64() < 0.5 {
+		n = n.Inv()
+	}
+	return
+}
+
+// emitArea counts both sides of the triangle
+func (tri Triangle) emitArea() float64 {
+	return 2 * tri.Area()
+}
+
+func (tri Triangle) Material() (m Material) {
+	return tri.Mat
+}
+
+// Pos returns the centroid of the triangle
+func (tri Triangle) Pos() (p geom.Vec3) {
+	return tri.V[0].Plus(tri.V[1]).Plus(tri.V[2]).Scale(1.0 / 3.0)
+}
+
+// Normal returns the geometric normal of the triangle,
+// following the counter-clockwise winding of its vertices
+func (tri Triangle) Normal() geom.Vec3 {
+	e1 := tri.V[1].Minus(tri.V[0])
+	e2 := tri.V[2].Minus(tri.V[0])
+	return e1.Cross(e2).Unit()
+}
+
+// Area returns the surface area of the triangle
+func (tri Triangle) Area() float64 {
+	e1 := tri.V[1].Minus(tri.V[0])
+	e2 := tri.V[2].Minus(tri.V[0])
+	return 0.5 * e1.Cross(e2).Len()
+}
+
+// Bounds returns the bounding box of the triangle
+func (tri Triangle) Bounds() Bounds {
+	return NewBounds(tri.V[0], tri.V[1], tri.V[2])
+}
+
+// Interpolate returns the point with barycentric coordinates b
+func (tri Triangle) Interpolate(b [3]float64) geom.Vec3 {
+	return tri.V[0].Scale(b[0]).Plus(tri.V[1].Scale(b[1])).Plus(tri.V[2].Scale(b[2]))
+}
+
+// ShadingNormal returns the interpolated vertex normal at the
+// barycentric coordinates b, or the geometric normal if the
+// triangle has no vertex normals
+func (tri Triangle) ShadingNormal(b [3]float64) geom.Vec3 {
+	if !tri.HasNormals {
+		return tri.Normal()
+	}
+	n := tri.N[0].Scale(b[0]).Plus(tri.N[1].Scale(b[1])).Plus(tri.N[2].Scale(b[2]))
+	if n.NearZero() {
+		return tri.Normal()
+	}
+	return n.Unit()
+}
+
+// TexCoord returns the interpolated UV coordinates at the barycentric
+// coordinates b. Unmapped triangles use the barycentrics themselves.
+func (tri Triangle) TexCoord(b [3]float64) (u, v float64) {
+	if !tri.HasUVs {
+		return b[1], b[2]
+	}
+	u = b[0]*tri.UV[0][0] + b[1]*tri.UV[1][0] + b[2]*tri.UV[2][0]
+	v = b[0]*tri.UV[0][1] + b[1]*tri.UV[1][1] + b[2]*tri.UV[2][1]
+	return
+}
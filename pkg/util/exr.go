@@ -0,0 +1,176 @@
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// EXROptions selects the pixel type and compression of OpenEXR images
+type EXROptions struct {
+	Float bool // 32-bit float channels instead of 16-bit half floats
+	Zip   bool // zlib compression of blocks of 16 scanlines
+}
+
+// OpenEXR pixel types and compression methods
+const (
+	exrHalf  = 1
+	exrFloat = 2
+
+	exrNoCompression  = 0
+	exrZipCompression = 3
+)
+
+// WriteEXR writes img as a single part, scanline OpenEXR image
+// with R, G and B channels
+func WriteEXR(w io.Writer, img *FloatImage, opt EXROptions) error {
+	pixelType, size := int32(exrHalf), 2
+	if opt.Float {
+		pixelType, size = exrFloat, 4
+	}
+	compression, lines := byte(exrNoCompression), 1
+	if opt.Zip {
+		compression, lines = exrZipCompression, 16
+	}
+
+	var header bytes.Buffer
+	header.Write(littleEndian(uint32(20000630), uint32(2)))
+	attr := func(name, typ string, value []byte) {
+		header.WriteString(name + "\x00" + typ + "\x00")
+		header.Write(littleEndian(int32(len(value))))
+		header.Write(value)
+	}
+	// Channels are stored in alphabetical order
+	var channels bytes.Buffer
+	for _, name := range []string{"B", "G", "R"} {
+		channels.WriteString(name + "\x00")
+		channels.Write(littleEndian(pixelType, [4]byte{}, [2]int32{1, 1}))
+	}
+	channels.WriteByte(0)
+	window := littleEndian([4]int32{0, 0, int32(img.W - 1), int32(img.H - 1)})
+	attr("channels", "chlist", channels.Bytes())
+	attr("compression", "compression", []byte{compression})
+	attr("dataWindow", "box2i", window)
+	attr("displayWindow", "box2i", window)
+	attr("lineOrder", "lineOrder", []byte{0})
+	attr("pixelAspectRatio", "float", littleEndian(float32(1)))
+	attr("screenWindowCenter", "v2f", littleEndian([2]float32{0, 0}))
+	attr("screenWindowWidth", "float", littleEndian(float32(1)))
+	header.WriteByte(0)
+
+	// Blocks of scanlines, each with its own offset in the file
+	nblocks := (img.H + lines - 1) / lines
+	offsets := make([]uint64, nblocks)
+	var blocks bytes.Buffer
+	raw := make([]byte, 0, 3*size*img.W*lines)
+	for b := 0; b < nblocks; b++ {
+		raw = raw[:0]
+		for y := b * lines; y < (b+1)*lines && y < img.H; y++ {
+			row := img.Pix[3*y*img.W : 3*(y+1)*img.W]
+			for c := 2; c >= 0; c-- {
+				for x := 0; x < img.W; x++ {
+					v := row[3*x+c]
+					if opt.Float {
+						f := math.Float32bits(v)
+						raw = append(raw, byte(f), byte(f>>8), byte(f>>16), byte(f>>24))
+					} else {
+						h := halfBits(v)
+						raw = append(raw, byte(h), byte(h>>8))
+					}
+				}
+			}
+		}
+		data := raw
+		if opt.Zip {
+			// Blocks that do not compress are stored as they are
+			if z := exrZip(raw); len(z) < len(raw) {
+				data = z
+			}
+		}
+		offsets[b] = uint64(header.Len() + 8*nblocks + blocks.Len())
+		blocks.Write(littleEndian(int32(b*lines), int32(len(data))))
+		blocks.Write(data)
+	}
+
+	for _, part := range [][]byte{header.Bytes(), littleEndian(offsets), blocks.Bytes()} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exrZip compresses a block of scanlines the way OpenEXR does, with
+// the bytes split by their parity and stored as differences before
+// going through zlib
+func exrZip(raw []byte) []byte {
+	tmp := make([]byte, len(raw))
+	half := (len(raw) + 1) / 2
+	for i, b := range raw {
+		if i%2 == 0 {
+			tmp[i/2] = b
+		} else {
+			tmp[half+i/2] = b
+		}
+	}
+	for i := len(tmp) - 1; i > 0; i-- {
+		tmp[i] = tmp[i] - tmp[i-1] + 128
+	}
+
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write(tmp)
+	zw.Close()
+	return b.Bytes()
+}
+
+// halfBits returns the IEEE 754 half precision bits of f,
+// rounding to the nearest even value
+func halfBits(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	if exp == 0xff { // infinity or NaN
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+	e := exp - 127 + 15
+	switch {
+	case e >= 0x1f: // too large
+		return sign | 0x7c00
+	case e <= 0: // subnormal or zero
+		if e < -10 {
+			return sign
+		}
+		m := mant | 0x800000
+		shift := uint(14 - e)
+		h := uint16(m >> shift)
+		rem, mid := m&(1<<shift-1), uint32(1)<<(shift-1)
+		if rem > mid || (rem == mid && h&1 == 1) {
+			h++
+		}
+		return sign | h
+	}
+	// A carry out of the mantissa correctly rounds up the exponent
+	h := uint16(e)<<10 | uint16(mant>>13)
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && h&1 == 1) {
+		h++
+	}
+	return sign | h
+}
+
+// littleEndian returns the little endian encoding of values
+func littleEndian(values ...interface{}) []byte {
+	var b bytes.Buffer
+	for _, v := range values {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHalfBits(t *testing.T) {
+	for _, c := range []struct {
+		f    float64
+		want uint16
+	}{
+		{0, 0x0000},
+		{math.Copysign(0, -1), 0x8000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{1.0 / 3, 0x3555},
+		{1 + 1.0/2048, 0x3c00},   // tie rounds to even
+		{1 + 3.0/2048, 0x3c02},   // tie rounds to even
+		{math.Exp2(-14), 0x0400}, // smallest normal
+		{math.Exp2(-24), 0x0001}, // smallest subnormal
+		{1023 * math.Exp2(-24), 0x03ff},
+		{3 * math.Exp2(-25), 0x0002}, // tie rounds to even
+		{math.Exp2(-25), 0x0000},     // tie rounds to even
+		{math.Exp2(-30), 0x0000},
+		{65504, 0x7bff}, // largest half
+		{65519, 0x7bff},
+		{65520, 0x7c00}, // rounds to infinity
+		{1e6, 0x7c00},
+		{-1e6, 0xfc00},
+		{math.Inf(1), 0x7c00},
+		{math.Inf(-1), 0xfc00},
+		{math.NaN(), 0x7e00},
+	} {
+		if got := halfBits(float32(c.f)); got != c.want {
+			t.Errorf("halfBits(%g) = %#04x, want %#04x", c.f, got, c.want)
+		}
+	}
+}
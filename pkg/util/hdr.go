@@ -0,0 +1,104 @@
+package util
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FloatImage holds linear RGB pixels row by row from the top left corner
+type FloatImage struct {
+	W, H int
+	Pix  []float32 // red, green and blue of each pixel
+}
+
+// NewFloatImage returns a black FloatImage of w by h pixels
+func NewFloatImage(w, h int) *FloatImage {
+	return &FloatImage{W: w, H: h, Pix: make([]float32, 3*w*h)}
+}
+
+// IsFloatImage tells if the file extension of name
+// is one of the floating point image formats
+func IsFloatImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".exr", ".hdr", ".pfm":
+		return true
+	}
+	return false
+}
+
+// SaveFloatImage writes img to the named OpenEXR, Radiance HDR or
+// PFM file, chosen by its extension
+func SaveFloatImage(name string, img *FloatImage, exr EXROptions) {
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".exr":
+		err = WriteEXR(f, img, exr)
+	case ".hdr":
+		err = WriteHDR(f, img)
+	case ".pfm":
+		err = WritePFM(f, img)
+	default:
+		err = fmt.Errorf("%s: unknown floating point image format", name)
+	}
+	if err != nil {
+		f.Close()
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Image", name, "saved")
+}
+
+// WriteHDR writes img as a Radiance RGBE image,
+// with flat (not run length encoded) scanlines
+func WriteHDR(w io.Writer, img *FloatImage) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", img.H, img.W)
+	for i := 0; i < len(img.Pix); i += 3 {
+		rgbe := toRGBE(img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+		if _, err := bw.Write(rgbe[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// toRGBE encodes a color as three mantissas sharing an exponent
+func toRGBE(r, g, b float32) (rgbe [4]byte) {
+	v := math.Max(float64(r), math.Max(float64(g), float64(b)))
+	if v < 1e-32 {
+		return
+	}
+	m, e := math.Frexp(v)
+	scale := m * 256 / v
+	rgbe[0] = byte(math.Max(0, float64(r)) * scale)
+	rgbe[1] = byte(math.Max(0, float64(g)) * scale)
+	rgbe[2] = byte(math.Max(0, float64(b)) * scale)
+	rgbe[3] = byte(e + 128)
+	return
+}
+
+// WritePFM writes img as a little endian Portable Float Map,
+// whose rows go from the bottom of the image to the top
+func WritePFM(w io.Writer, img *FloatImage) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "PF\n%d %d\n-1.0\n", img.W, img.H)
+	for y := img.H - 1; y >= 0; y-- {
+		row := img.Pix[3*y*img.W : 3*(y+1)*img.W]
+		if err := binary.Write(bw, binary.LittleEndian, row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
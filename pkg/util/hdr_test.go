@@ -0,0 +1,39 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestWriteHDR(t *testing.T) {
+	img := NewFloatImage(3, 1)
+	copy(img.Pix, []float32{1, 0.5, 0.25, 0, 0, 0, 3, 0, 1})
+	var buf bytes.Buffer
+	if err := WriteHDR(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 3\n"),
+		128, 64, 32, 129, // 1 is 0.5 times 2
+		0, 0, 0, 0,
+		192, 0, 64, 130, // 3 is 0.75 times 4
+	)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("WriteHDR wrote %q, want %q", buf.Bytes(), want)
+	}
+}
+
+func TestWritePFM(t *testing.T) {
+	img := NewFloatImage(1, 2)
+	copy(img.Pix, []float32{1, 2, 3, 4, 5, 6})
+	var buf bytes.Buffer
+	if err := WritePFM(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.NewBufferString("PF\n1 2\n-1.0\n")
+	// Rows go from the bottom of the image to the top
+	binary.Write(want, binary.LittleEndian, []float32{4, 5, 6, 1, 2, 3})
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+		t.Errorf("WritePFM wrote %q, want %q", buf.Bytes(), want.Bytes())
+	}
+}